@@ -0,0 +1,96 @@
+package jsonschema
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig"
+)
+
+// strictAdapterName is the name of the StrictAdapter config adapter.
+const strictAdapterName = "json-schema-strict"
+
+func init() {
+	caddyconfig.RegisterAdapter(strictAdapterName, StrictAdapter{})
+}
+
+var _ caddyconfig.Adapter = StrictAdapter{}
+
+// StrictAdapter is a config adapter that validates the config against
+// the JSON schema of the current build before it is loaded. Unknown
+// properties and values of the wrong type are rejected, Caddy silently
+// ignores them otherwise.
+//
+// It is registered as the json-schema-strict adapter for JSON configs:
+//
+//	caddy run --config caddy.json --adapter json-schema-strict
+type StrictAdapter struct {
+	// Adapter adapts the config to JSON before validation.
+	// If nil, the config must be JSON.
+	Adapter caddyconfig.Adapter `json:"-"`
+}
+
+// Adapt implements caddyconfig.Adapter. The config is returned unchanged
+// if valid.
+func (a StrictAdapter) Adapt(body []byte, options map[string]interface{}) ([]byte, []caddyconfig.Warning, error) {
+	var warnings []caddyconfig.Warning
+	if a.Adapter != nil {
+		var err error
+		if body, warnings, err = a.Adapter.Adapt(body, options); err != nil {
+			return nil, warnings, err
+		}
+	}
+
+	schema, err := loadValidationSchema()
+	if err != nil {
+		return nil, warnings, fmt.Errorf("generating schema: %v", err)
+	}
+
+	errs, err := schema.ValidateStrict(body)
+	if err != nil {
+		return nil, warnings, err
+	}
+	if len(errs) == 0 {
+		return body, warnings, nil
+	}
+
+	filename, _ := options["filename"].(string)
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		if a.Adapter != nil {
+			// positions refer to the adapted JSON
+			e.Line, e.Column = 0, 0
+		}
+		msgs[i] = e.Error()
+		if filename != "" {
+			msgs[i] = filename + ":" + msgs[i]
+		}
+	}
+	return nil, warnings, fmt.Errorf("config does not match the schema, %d errors:\n%s", len(errs), strings.Join(msgs, "\n"))
+}
+
+// validationSchema is the schema configs are validated against. It is
+// generated once per process without docs.
+var validationSchema struct {
+	sync.Mutex
+	schema *Schema
+}
+
+// loadValidationSchema generates the schema configs are validated against.
+// Failures are not kept, generation is retried on the next config.
+func loadValidationSchema() (*Schema, error) {
+	validationSchema.Lock()
+	defer validationSchema.Unlock()
+	if validationSchema.schema != nil {
+		return validationSchema.schema, nil
+	}
+
+	schema, err := Generator{}.Generate(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	validationSchema.schema = schema
+	return schema, nil
+}
@@ -0,0 +1,57 @@
+package jsonschema
+
+import (
+	"strings"
+	"testing"
+
+	_ "github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+func TestStrictAdapter(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		errors []string // error lines after the summary
+	}{
+		{
+			name:   "valid",
+			config: `{"apps":{"http":{"servers":{"srv0":{"listen":[":8080"],"routes":[{"handle":[{"handler":"static_response","body":"ok"}]}]}}}}}`,
+		},
+		{
+			name:   "id",
+			config: `{"apps":{"http":{"servers":{"srv0":{"@id":"srv0","routes":[{"@id":"route","handle":[{"@id":"ok","handler":"static_response"}]}]}}}}}`,
+		},
+		{
+			name:   "unknown key",
+			config: `{"apps":{"http":{"servers":{"srv0":{"routes":[{"handel":[{"handler":"static_response"}]}]}}}}}`,
+			errors: []string{`caddy.json:1:48: /apps/http/servers/srv0/routes/0/handel: unknown property "handel"`},
+		},
+		{
+			name:   "wrong type",
+			config: `{"apps":{"http":{"servers":{"srv0":{"listen":":8080"}}}}}`,
+			errors: []string{`caddy.json:1:37: /apps/http/servers/srv0/listen: expected array, found string`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := map[string]interface{}{"filename": "caddy.json"}
+			out, _, err := StrictAdapter{}.Adapt([]byte(tt.config), options)
+			if len(tt.errors) == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(out) != tt.config {
+					t.Errorf("got config %s, want %s", out, tt.config)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("got no error for invalid config")
+			}
+			lines := strings.Split(err.Error(), "\n")[1:]
+			if strings.Join(lines, "\n") != strings.Join(tt.errors, "\n") {
+				t.Errorf("got errors\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(tt.errors, "\n"))
+			}
+		})
+	}
+}
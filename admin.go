@@ -0,0 +1,154 @@
+package jsonschema
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/caddyserver/caddy/v2"
+)
+
+func init() {
+	caddy.RegisterModule(AdminAPI{})
+}
+
+var _ caddy.AdminRouter = (*AdminAPI)(nil)
+
+// AdminAPI is a module that serves the JSON schema of the running Caddy
+// instance through the admin API.
+//
+//	GET /schema             full schema
+//	GET /schema/<module-id> schema of a single module
+//
+// The schema is generated once on first request, with docs from the
+// local docs cache if available.
+type AdminAPI struct{}
+
+// CaddyModule returns the Caddy module information.
+func (AdminAPI) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "admin.api.json_schema",
+		New: func() caddy.Module { return new(AdminAPI) },
+	}
+}
+
+// Routes returns the admin routes for the JSON schema.
+func (a *AdminAPI) Routes() []caddy.AdminRoute {
+	return []caddy.AdminRoute{
+		{Pattern: "/schema", Handler: caddy.AdminHandlerFunc(a.handleSchema)},
+		{Pattern: "/schema/", Handler: caddy.AdminHandlerFunc(a.handleSchema)},
+	}
+}
+
+// adminSchema is the schema served by the admin API. It is shared by
+// all AdminAPI instances as the modules in a build do not change.
+var adminSchema struct {
+	sync.Mutex
+	schema *Schema
+}
+
+// loadAdminSchema generates the schema served by the admin API. Only
+// successfully generated schemas are kept, failures are retried on the
+// next request.
+func loadAdminSchema() (*Schema, error) {
+	adminSchema.Lock()
+	defer adminSchema.Unlock()
+	if adminSchema.schema != nil {
+		return adminSchema.schema, nil
+	}
+
+	ctx := context.Background()
+	schema, err := Generator{Docs: WebDocs{Offline: true}}.Generate(ctx)
+	if err != nil {
+		// docs not cached, fallback to no docs
+		schema, err = Generator{}.Generate(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	adminSchema.schema = schema
+	return schema, nil
+}
+
+func (a *AdminAPI) handleSchema(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return caddy.APIError{
+			HTTPStatus: http.StatusMethodNotAllowed,
+			Err:        fmt.Errorf("method not allowed"),
+		}
+	}
+
+	schema, err := loadAdminSchema()
+	if err != nil {
+		return caddy.APIError{
+			HTTPStatus: http.StatusInternalServerError,
+			Err:        fmt.Errorf("generating schema: %v", err),
+		}
+	}
+
+	var v interface{} = schema
+	if id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/schema"), "/"); id != "" {
+		def, ok := moduleSchema(schema, id)
+		if !ok {
+			return caddy.APIError{
+				HTTPStatus: http.StatusNotFound,
+				Err:        fmt.Errorf("unknown module '%s'", id),
+			}
+		}
+		v = def
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return caddy.APIError{
+			HTTPStatus: http.StatusInternalServerError,
+			Err:        err,
+		}
+	}
+
+	sum := sha256.Sum256(b)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if match := r.Header.Get("If-None-Match"); match != "" && strings.Contains(match, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(b)
+	return err
+}
+
+// moduleSchema returns the definition of the module id in schema as a
+// standalone schema, with the definitions it references.
+func moduleSchema(schema *Schema, id string) (*Schema, bool) {
+	def, ok := schema.Definitions[id]
+	if !ok {
+		return nil, false
+	}
+
+	s := *def
+	s.SchemaURI = schema.SchemaURI
+	s.Definitions = map[string]*Schema{}
+	var addRefs func(*Schema)
+	addRefs = func(sub *Schema) {
+		sub.walk(func(sub *Schema) {
+			if !strings.HasPrefix(sub.Ref, "#/definitions/") {
+				return
+			}
+			name := unescapePointer(strings.TrimPrefix(sub.Ref, "#/definitions/"))
+			if ref, ok := schema.Definitions[name]; ok && s.Definitions[name] == nil {
+				s.Definitions[name] = ref
+				addRefs(ref)
+			}
+		})
+	}
+	addRefs(def)
+	return &s, true
+}
@@ -0,0 +1,52 @@
+package jsonschema
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2"
+	_ "github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+type testAdminModule struct {
+	MatchersRaw caddy.ModuleMap `json:"match,omitempty" caddy:"namespace=http.matchers"`
+}
+
+func (testAdminModule) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{ID: "test.admin", New: func() caddy.Module { return new(testAdminModule) }}
+}
+
+func init() {
+	caddy.RegisterModule(testAdminModule{})
+}
+
+func TestModuleSchema(t *testing.T) {
+	s := generateTest(t, Generator{}, "")
+
+	m, ok := moduleSchema(s, "test.admin")
+	if !ok {
+		t.Fatal("module not found")
+	}
+	if m.SchemaURI != s.SchemaURI {
+		t.Errorf("got $schema %q, want %q", m.SchemaURI, s.SchemaURI)
+	}
+	if m.Definitions["http.matchers.path"] == nil {
+		t.Errorf("referenced definition missing: %v", m.Definitions)
+	}
+	if m.Definitions["test.admin"] != nil || m.Definitions["test.undocumented"] != nil {
+		t.Error("unreferenced definitions included")
+	}
+	m.walk(func(sub *Schema) {
+		if sub.Ref == "" {
+			return
+		}
+		name := unescapePointer(strings.TrimPrefix(sub.Ref, "#/definitions/"))
+		if m.Definitions[name] == nil {
+			t.Errorf("unresolved $ref %s", sub.Ref)
+		}
+	})
+
+	if _, ok := moduleSchema(s, "test.unknown"); ok {
+		t.Error("unknown module found")
+	}
+}
@@ -0,0 +1,146 @@
+package jsonschema
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// bundleFormat is the current format version of docs bundles.
+const bundleFormat = 1
+
+// docBundle is a docs bundle, an archive of the documentation for the
+// root config and all documented modules. It is stored as gzip
+// compressed JSON.
+type docBundle struct {
+	Format       int                    `json:"format"`
+	CaddyVersion string                 `json:"caddy_version"`
+	CreatedAt    time.Time              `json:"created_at"`
+	Root         DocAPIResp             `json:"root"`
+	Modules      map[string]*DocAPIResp `json:"modules"`
+}
+
+var _ DocSource = BundleDocs{}
+
+// BundleDocs is a DocSource that reads documentation exclusively from
+// a docs bundle file, created with `caddy json-schema docs export`.
+// It requires no network access.
+type BundleDocs struct {
+	// File is the path to the docs bundle.
+	File string
+}
+
+// LoadDocs implements DocSource.
+func (b BundleDocs) LoadDocs(ctx context.Context) (*Docs, error) {
+	bundle, err := readBundle(b.File)
+	if err != nil {
+		return nil, err
+	}
+	if bundle.CaddyVersion != caddyVersion() {
+		log.Printf("docs bundle is for Caddy %s, current build is %s.", bundle.CaddyVersion, caddyVersion())
+	}
+
+	return &Docs{
+		Root:    bundle.Root,
+		Modules: bundle.Modules,
+		Errors:  map[string]error{},
+	}, nil
+}
+
+// writeBundle writes docs to filename as a docs bundle.
+func writeBundle(docs *Docs, filename string) error {
+	bundle := docBundle{
+		Format:       bundleFormat,
+		CaddyVersion: caddyVersion(),
+		CreatedAt:    time.Now().UTC(),
+		Root:         docs.Root,
+		Modules:      map[string]*DocAPIResp{},
+	}
+	for path, doc := range docs.Modules {
+		if doc != nil {
+			bundle.Modules[path] = doc
+		}
+	}
+
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, filePerm)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zw := gzip.NewWriter(f)
+	if err := json.NewEncoder(zw).Encode(bundle); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// readBundle reads the docs bundle in filename.
+func readBundle(filename string) (*docBundle, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid docs bundle '%s': %v", filename, err)
+	}
+	defer zr.Close()
+
+	var bundle docBundle
+	if err := json.NewDecoder(zr).Decode(&bundle); err != nil {
+		return nil, fmt.Errorf("invalid docs bundle '%s': %v", filename, err)
+	}
+	if bundle.Format < 1 || bundle.Format > bundleFormat {
+		return nil, fmt.Errorf("unsupported docs bundle format %d, expected at most %d", bundle.Format, bundleFormat)
+	}
+	if bundle.Modules == nil {
+		bundle.Modules = map[string]*DocAPIResp{}
+	}
+	return &bundle, nil
+}
+
+// importBundle unpacks the docs bundle in filename into the local docs
+// cache of the current Caddy version. It returns the number of imported
+// docs.
+func importBundle(filename string) (int, error) {
+	bundle, err := readBundle(filename)
+	if err != nil {
+		return 0, err
+	}
+	if bundle.CaddyVersion != caddyVersion() {
+		log.Printf("docs bundle is for Caddy %s, importing for %s.", bundle.CaddyVersion, caddyVersion())
+	}
+
+	meta := cacheMeta{FetchedAt: time.Now()}
+	write := func(namespace string, doc *DocAPIResp) error {
+		b, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		return writeCache(namespace, b, meta)
+	}
+
+	if err := write("", &bundle.Root); err != nil {
+		return 0, err
+	}
+	n := 1
+	for path, doc := range bundle.Modules {
+		if doc == nil {
+			continue
+		}
+		if err := write("apps/"+path, doc); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
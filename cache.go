@@ -0,0 +1,235 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+)
+
+const (
+	cacheDocFile  = "docs.json"
+	cacheMetaFile = "docs.meta.json"
+)
+
+// cacheMeta is the metadata stored alongside a cached API doc.
+type cacheMeta struct {
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+}
+
+// expired reports if the cached doc is older than ttl.
+// A zero ttl never expires.
+func (c cacheMeta) expired(ttl time.Duration) bool {
+	return ttl > 0 && time.Since(c.FetchedAt) > ttl
+}
+
+// cacheEntry is a cached API doc.
+type cacheEntry struct {
+	Version   string
+	Namespace string
+	Meta      cacheMeta
+	dir       string
+}
+
+// cacheRoot returns the directory for all cached docs.
+func cacheRoot() string {
+	return filepath.Join(caddy.AppDataDir(), "json_schema")
+}
+
+// cacheVersionDir returns the directory for cached docs of the
+// Caddy version in the current build.
+func cacheVersionDir() string {
+	return filepath.Join(cacheRoot(), "docs", caddyVersion())
+}
+
+// caddyVersion returns the version of Caddy in the current build,
+// sanitized for use as a directory name.
+func caddyVersion() string {
+	version := caddy.GoModule().Version
+	if version == "" || version == "(devel)" {
+		return "devel"
+	}
+	return strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(version)
+}
+
+// readCache reads the cached API doc for namespace.
+func readCache(namespace string) ([]byte, cacheMeta, error) {
+	var meta cacheMeta
+	dir := filepath.Join(cacheVersionDir(), namespace)
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, cacheDocFile))
+	if err != nil {
+		return nil, meta, err
+	}
+	// missing metadata is treated as expired
+	if m, err := ioutil.ReadFile(filepath.Join(dir, cacheMetaFile)); err == nil {
+		if err := json.Unmarshal(m, &meta); err != nil {
+			return nil, meta, err
+		}
+	}
+	return b, meta, nil
+}
+
+// writeCache writes the API doc for namespace to the cache.
+// If b is nil, only the metadata is updated.
+func writeCache(namespace string, b []byte, meta cacheMeta) error {
+	dir := filepath.Join(cacheVersionDir(), namespace)
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		return err
+	}
+	if b != nil {
+		if err := ioutil.WriteFile(filepath.Join(dir, cacheDocFile), b, filePerm); err != nil {
+			return err
+		}
+	}
+	m, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, cacheMetaFile), m, filePerm)
+}
+
+// listCache lists all cached API docs for all Caddy versions.
+func listCache() ([]cacheEntry, error) {
+	var entries []cacheEntry
+
+	docsDir := filepath.Join(cacheRoot(), "docs")
+	versions, err := ioutil.ReadDir(docsDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, version := range versions {
+		if !version.IsDir() {
+			continue
+		}
+		versionDir := filepath.Join(docsDir, version.Name())
+		err := filepath.Walk(versionDir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || info.Name() != cacheDocFile {
+				return nil
+			}
+
+			dir := filepath.Dir(path)
+			namespace, err := filepath.Rel(versionDir, dir)
+			if err != nil {
+				return err
+			}
+			entry := cacheEntry{
+				Version:   version.Name(),
+				Namespace: strings.TrimPrefix(filepath.ToSlash(namespace), "."),
+				dir:       dir,
+			}
+			if m, err := ioutil.ReadFile(filepath.Join(dir, cacheMetaFile)); err == nil {
+				_ = json.Unmarshal(m, &entry.Meta)
+			}
+			entries = append(entries, entry)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return entries, nil
+}
+
+// pruneCache removes cached docs for other Caddy versions, docs older
+// than ttl and docs cached in the legacy unversioned layout.
+// It returns the number of removed entries.
+func pruneCache(ttl time.Duration) (int, error) {
+	entries, err := listCache()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	current := caddyVersion()
+	for _, entry := range entries {
+		if entry.Version == current && !entry.Meta.expired(ttl) {
+			continue
+		}
+		for _, name := range []string{cacheDocFile, cacheMetaFile} {
+			if err := os.Remove(filepath.Join(entry.dir, name)); err != nil && !os.IsNotExist(err) {
+				return removed, err
+			}
+		}
+		removed++
+	}
+
+	n, err := pruneLegacyCache()
+	removed += n
+	if err != nil {
+		return removed, err
+	}
+
+	// other Caddy versions
+	versions, err := ioutil.ReadDir(filepath.Join(cacheRoot(), "docs"))
+	if err != nil && !os.IsNotExist(err) {
+		return removed, err
+	}
+	for _, version := range versions {
+		if version.Name() == current {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(cacheRoot(), "docs", version.Name())); err != nil {
+			return removed, err
+		}
+	}
+
+	return removed, nil
+}
+
+// pruneLegacyCache removes docs cached in the legacy unversioned
+// layout, in namespace directories of the root directory. Other files
+// in the root directory are kept. It returns the number of removed
+// entries.
+func pruneLegacyCache() (int, error) {
+	removed := 0
+	root := cacheRoot()
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() && path == filepath.Join(root, "docs") {
+			return filepath.SkipDir
+		}
+		if info.IsDir() || info.Name() != cacheDocFile {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed++
+		// remove the namespace directories left empty
+		for dir := filepath.Dir(path); dir != root; dir = filepath.Dir(dir) {
+			if os.Remove(dir) != nil {
+				break
+			}
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// clearCache removes all cached docs.
+func clearCache() error {
+	if _, err := pruneLegacyCache(); err != nil {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(cacheRoot(), "docs"))
+}
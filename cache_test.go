@@ -0,0 +1,91 @@
+package jsonschema
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testCacheDir sets up a temporary cache with the files and returns the
+// cache root directory and a cleanup function.
+func testCacheDir(t *testing.T, files []string) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "json-schema-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	os.Setenv("XDG_DATA_HOME", dir)
+	cleanup := func() {
+		os.Setenv("XDG_DATA_HOME", dataHome)
+		os.RemoveAll(dir)
+	}
+
+	root := cacheRoot()
+	for _, name := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), dirPerm); err != nil {
+			cleanup()
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte("{}"), filePerm); err != nil {
+			cleanup()
+			t.Fatal(err)
+		}
+	}
+	return root, cleanup
+}
+
+// legacy, current, outdated and foreign cache files.
+var testCacheFiles = map[string]struct{ pruned, cleared bool }{
+	cacheDocFile:                {true, true},
+	"apps/http/" + cacheDocFile: {true, true},
+	"apps/tls/" + cacheDocFile:  {true, true},
+	"apps/tls/notes.txt":        {false, false},
+	"notes.txt":                 {false, false},
+	"other/data.json":           {false, false},
+	"docs/" + caddyVersion() + "/" + cacheDocFile: {false, true},
+	"docs/v0.0.0/apps/http/" + cacheDocFile:       {true, true},
+}
+
+func TestPruneCache(t *testing.T) {
+	var files []string
+	for name := range testCacheFiles {
+		files = append(files, name)
+	}
+	root, cleanup := testCacheDir(t, files)
+	defer cleanup()
+
+	if _, err := pruneCache(0); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range testCacheFiles {
+		_, err := os.Stat(filepath.Join(root, filepath.FromSlash(name)))
+		if removed := os.IsNotExist(err); removed != want.pruned {
+			t.Errorf("%s: removed %v, want %v", name, removed, want.pruned)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "apps", "http")); !os.IsNotExist(err) {
+		t.Errorf("empty legacy directory not removed: %v", err)
+	}
+}
+
+func TestClearCache(t *testing.T) {
+	var files []string
+	for name := range testCacheFiles {
+		files = append(files, name)
+	}
+	root, cleanup := testCacheDir(t, files)
+	defer cleanup()
+
+	if err := clearCache(); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range testCacheFiles {
+		_, err := os.Stat(filepath.Join(root, filepath.FromSlash(name)))
+		if removed := os.IsNotExist(err); removed != want.cleared {
+			t.Errorf("%s: removed %v, want %v", name, removed, want.cleared)
+		}
+	}
+}
@@ -1,14 +1,5 @@
 package jsonschema
 
-// moduleMap is map of namespaces to namespace modules.
-// It is used by module loaders to identify modules in namespace.
-var moduleMap = map[string]Modules{}
-
-// flatModule is a flat map of module namespace to module
-// without nesting.
-// It is used during schema generation.
-var flatModuleMap = Modules{}
-
 // Module is a basic information about a Caddy module.
 type Module struct {
 	Name      string
@@ -20,14 +11,21 @@ type Module struct {
 // and ease of fetching module name.
 type Modules map[string]Module
 
-// rootDocAPIResp holds the value for the API response of the root
-// config.
-var rootDocAPIResp DocAPIResp
+// Docs is the documentation for the root config and modules.
+type Docs struct {
+	// Root holds the value for the API response of the root
+	// config.
+	Root DocAPIResp
 
-// flatCaddyDocMap is a flat map of module path to API response
-// without nesting.
-// It is used during schema generation to retrieve module docs.
-var flatCaddyDocMap = map[string]*DocAPIResp{}
+	// Modules is a flat map of module path to API response
+	// without nesting.
+	// It is used during schema generation to retrieve module docs.
+	Modules map[string]*DocAPIResp
+
+	// Errors are the errors encountered retrieving docs, mapped by
+	// module path.
+	Errors map[string]error
+}
 
 // DocStruct is the API response structure for a type.
 type DocStruct struct {
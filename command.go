@@ -1,11 +1,20 @@
 package jsonschema
 
 import (
+	"context"
 	"flag"
+	"fmt"
+	"io/ioutil"
 	stdlog "log"
 	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig"
 	caddycmd "github.com/caddyserver/caddy/v2/cmd"
 )
 
@@ -13,13 +22,29 @@ const commandName = "json-schema"
 
 var (
 	config = struct {
-		File         string
-		VsCode       bool
-		Indent       int
-		DiscardCache bool
+		File              string
+		VsCode            bool
+		Indent            int
+		DiscardCache      bool
+		CacheTTL          time.Duration
+		SourceDocs        bool
+		DocsBundle        string
+		Strict            bool
+		Placeholders      bool
+		PlaceholdersAllow string
+		PlaceholdersDeny  string
+		Draft             string
+		ID                string
+		Closed            bool
+		Required          bool
+		Defaults          bool
+		Examples          bool
+		ExamplesDir       string
 	}{
-		File:   "./caddy_schema.json",
-		Indent: 2,
+		File:     "./caddy_schema.json",
+		Indent:   2,
+		CacheTTL: 7 * 24 * time.Hour,
+		Draft:    string(Draft07),
 	}
 
 	log = stdlog.New(os.Stderr, commandName+" ", 0)
@@ -29,7 +54,7 @@ func init() {
 	caddycmd.RegisterCommand(caddycmd.Command{
 		Name:  commandName,
 		Func:  run,
-		Usage: "[--output <file>] [--indent <int>] [--vscode] [--no-cache]",
+		Usage: "[--output <file>] [--indent <int>] [--vscode] [--no-cache] [--cache-ttl <duration>] [--source-docs] [--docs <file>] [--strict] [--placeholders] [--placeholders-allow <list>] [--placeholders-deny <list>] [--draft <draft>] [--id <uri>] [--closed] [--required] [--defaults] [--examples] [--examples-dir <dir>]",
 		Short: "Generate JSON schema for Caddy JSON api",
 		Long: `
 JSON schema generator for caddy JSON configuration.
@@ -43,6 +68,64 @@ the value of '--indent'.
 If --no-cache is set, local documentation cache (if present) will be discard and the
 latest API docs will be retrieved from caddyserver.com.
 
+Cached documentation is kept per Caddy version. If --cache-ttl is set, cached docs
+older than the duration are revalidated with caddyserver.com. Defaults to 168h.
+
+The documentation cache can be managed with the cache subcommand.
+  caddy json-schema cache list             lists cached docs
+  caddy json-schema cache prune [--ttl d]  removes expired and outdated docs
+  caddy json-schema cache clear            removes all cached docs
+
+If --source-docs is set, documentation is generated from the Go doc comments of the
+module sources in the Go module cache or GOPATH instead of caddyserver.com. This
+requires no network access and documents modules not registered on caddyserver.com.
+
+If --docs is set, documentation is read exclusively from the docs bundle file.
+
+Docs bundles allow generation without network access e.g. in CI. The docs
+subcommand exports and imports bundles of the docs from the current doc source.
+  caddy json-schema docs export <file>     exports docs to a bundle file
+  caddy json-schema docs import <file>     imports a bundle file into the cache
+
+The validate subcommand validates a config against the schema of the current build.
+Errors are printed with JSON pointer paths and positions, the command exits with a
+non-zero code if the config is invalid. Non-JSON configs are converted with the config
+adapter specified by --adapter before validation. With --strict, unknown properties
+are rejected.
+  caddy json-schema validate [--adapter <name>] [--strict] <file>
+
+The json-schema-strict config adapter validates JSON configs before they are loaded.
+  caddy run --config caddy.json --adapter json-schema-strict
+
+Modules without documentation and module loader fields of unsupported types are
+reported at the end of the run. If --strict is set, the command fails if
+documentation is missing for any module other than the modules of this plugin.
+
+If --placeholders is set, integer, number and boolean fields also accept Caddy
+placeholders e.g. {env.PORT}. Placeholders can be limited to specific modules or
+fields with comma separated patterns in --placeholders-allow and
+--placeholders-deny e.g. 'http.handlers.*,tls.issuance.acme/email'. Fields are
+specified as '<module>/<field>'.
+
+If --draft is set, the schema is generated for the JSON schema draft. Supported drafts
+are draft-07, 2019-09 and 2020-12. Defaults to draft-07. If --id is set, it is used
+as the $id of the schema.
+
+If --closed is set, unknown properties in structs are rejected e.g. typos in field
+names. Caddy ignores unknown properties when loading a config.
+
+If --required is set, required properties are derived from the json tags without
+omitempty and by provisioning and validating zero-valued modules. Modules are
+provisioned in a throwaway config that is never started, and may log.
+
+If --defaults is set, the defaults of module properties are derived by provisioning
+zero-valued modules and recording the properties set.
+
+If --examples is set, examples are attached to the schema. They are taken from JSON
+blocks in the docs and the adaptation of Caddyfile snippets of common modules.
+If --examples-dir is set, examples are also read from the directory, from files
+named '<module ID>.json' containing a JSON array of examples. It implies --examples.
+
 If --vscode is set, schema and vscode config is generated into a '.vscode' directory
 in the current working directory. This disregards '--output'.
 Other ways of integrating JSON schema in VSCode can be found at
@@ -54,17 +137,64 @@ https://code.visualstudio.com/docs/languages/json#_mapping-in-the-user-settings
 			fs.BoolVar(&config.VsCode, "vscode", config.VsCode, "Generate VSCode configuration")
 			fs.IntVar(&config.Indent, "indent", config.Indent, "Number of spaces to indent the generated JSON with")
 			fs.BoolVar(&config.DiscardCache, "no-cache", config.DiscardCache, "Discard local cache and fetch latest API docs")
+			fs.DurationVar(&config.CacheTTL, "cache-ttl", config.CacheTTL, "Duration after which cached API docs are revalidated")
+			fs.BoolVar(&config.SourceDocs, "source-docs", config.SourceDocs, "Generate docs from Go source comments instead of caddyserver.com")
+			fs.StringVar(&config.DocsBundle, "docs", config.DocsBundle, "Read docs exclusively from the docs bundle file")
+			fs.BoolVar(&config.Strict, "strict", config.Strict, "Fail if documentation is missing for any module other than the modules of this plugin")
+			fs.BoolVar(&config.Placeholders, "placeholders", config.Placeholders, "Allow Caddy placeholders in non-string fields")
+			fs.StringVar(&config.PlaceholdersAllow, "placeholders-allow", config.PlaceholdersAllow, "Comma separated modules or fields that accept placeholders")
+			fs.StringVar(&config.PlaceholdersDeny, "placeholders-deny", config.PlaceholdersDeny, "Comma separated modules or fields that do not accept placeholders")
+			fs.StringVar(&config.Draft, "draft", config.Draft, "JSON schema draft of the generated schema: draft-07, 2019-09 or 2020-12")
+			fs.StringVar(&config.ID, "id", config.ID, "The $id of the generated schema")
+			fs.BoolVar(&config.Closed, "closed", config.Closed, "Reject unknown properties in structs")
+			fs.BoolVar(&config.Required, "required", config.Required, "Derive required properties from the modules")
+			fs.BoolVar(&config.Defaults, "defaults", config.Defaults, "Derive defaults of properties by provisioning the modules")
+			fs.BoolVar(&config.Examples, "examples", config.Examples, "Attach examples to the schema")
+			fs.StringVar(&config.ExamplesDir, "examples-dir", config.ExamplesDir, "Directory of examples for modules, implies --examples")
 			return fs
 		}(),
 	})
 }
 
+// subcommands are the subcommands of the json-schema command.
+var subcommands = map[string]func(args []string) (int, error){
+	"cache":    runCache,
+	"docs":     runDocs,
+	"validate": runValidate,
+}
+
 func run(fs caddycmd.Flags) (int, error) {
-	if err := loadDoc(); err != nil {
-		return caddy.ExitCodeFailedQuit, err
+	if cmd, ok := subcommands[fs.Arg(0)]; ok {
+		return cmd(fs.Args()[1:])
+	}
+
+	g := Generator{
+		Docs:     docSource(),
+		Strict:   config.Strict,
+		Draft:    Draft(config.Draft),
+		ID:       config.ID,
+		Closed:   config.Closed,
+		Required: config.Required,
+		Defaults: config.Defaults,
+	}
+	if config.Examples || config.ExamplesDir != "" {
+		g.Examples = &Examples{Dir: config.ExamplesDir}
+	}
+	if config.Placeholders {
+		g.Placeholders = &Placeholders{
+			Allow: splitList(config.PlaceholdersAllow),
+			Deny:  splitList(config.PlaceholdersDeny),
+		}
 	}
 
-	if err := generateSchema(); err != nil {
+	ctx, cancel := interruptContext()
+	defer cancel()
+
+	schema, report, err := g.GenerateWithReport(ctx)
+	if report != nil {
+		logReport(report)
+	}
+	if err != nil {
 		return caddy.ExitCodeFailedQuit, err
 	}
 
@@ -76,9 +206,253 @@ func run(fs caddycmd.Flags) (int, error) {
 			w = basicWriter{}
 		}
 	}
-	if err := writeToFile(w); err != nil {
+	if err := writeToFile(w, schema); err != nil {
 		return caddy.ExitCodeFailedQuit, err
 	}
 
 	return 0, nil
 }
+
+// docSource returns the doc source configured with flags.
+func docSource() DocSource {
+	switch {
+	case config.DocsBundle != "":
+		return BundleDocs{File: config.DocsBundle}
+	case config.SourceDocs:
+		return SourceDocs{}
+	}
+	return WebDocs{
+		DiscardCache: config.DiscardCache,
+		CacheTTL:     config.CacheTTL,
+		Retries:      defaultRetries,
+	}
+}
+
+// logReport logs the problems in the generation report.
+func logReport(r *Report) {
+	if r.Empty() {
+		return
+	}
+
+	if len(r.DocErrors) > 0 {
+		var paths []string
+		for path := range r.DocErrors {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+
+		log.Println("errors retrieving docs:")
+		for _, path := range paths {
+			log.Printf("  %s: %v", path, r.DocErrors[path])
+		}
+	}
+
+	if len(r.Undocumented) > 0 {
+		log.Println("modules without documentation:")
+		for _, mod := range r.Undocumented {
+			log.Println(" ", mod)
+		}
+	}
+
+	if len(r.UnsupportedLoaders) > 0 {
+		log.Println("module loader fields of unsupported types, accepting any value:")
+		for _, field := range r.UnsupportedLoaders {
+			log.Println(" ", field)
+		}
+	}
+
+	if len(r.ProbeErrors) > 0 {
+		var mods []string
+		for mod := range r.ProbeErrors {
+			mods = append(mods, mod)
+		}
+		sort.Strings(mods)
+
+		log.Println("errors probing modules:")
+		for _, mod := range mods {
+			log.Printf("  %s: %v", mod, r.ProbeErrors[mod])
+		}
+	}
+}
+
+// interruptContext returns a context that is cancelled on interrupt
+// signal e.g. Ctrl-C.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		defer signal.Stop(sig)
+		select {
+		case <-sig:
+			log.Println("interrupted, cancelling...")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, cancel
+}
+
+func runCache(args []string) (int, error) {
+	fs := flag.NewFlagSet(commandName+" cache", flag.ExitOnError)
+	ttl := fs.Duration("ttl", config.CacheTTL, "Duration after which cached API docs are expired")
+
+	if len(args) == 0 {
+		return caddy.ExitCodeFailedQuit, fmt.Errorf("missing cache action, expected list, prune or clear")
+	}
+	action := args[0]
+	if err := fs.Parse(args[1:]); err != nil {
+		return caddy.ExitCodeFailedQuit, err
+	}
+
+	switch action {
+	case "list":
+		entries, err := listCache()
+		if err != nil {
+			return caddy.ExitCodeFailedQuit, err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAMESPACE\tFETCHED\tEXPIRED")
+		for _, entry := range entries {
+			namespace := entry.Namespace
+			if namespace == "" {
+				namespace = "(root config)"
+			}
+			fetched := "-"
+			if !entry.Meta.FetchedAt.IsZero() {
+				fetched = entry.Meta.FetchedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%t\n", entry.Version, namespace, fetched, entry.Meta.expired(*ttl))
+		}
+		return 0, w.Flush()
+
+	case "prune":
+		n, err := pruneCache(*ttl)
+		if err != nil {
+			return caddy.ExitCodeFailedQuit, err
+		}
+		log.Println(n, "cached docs pruned.")
+
+	case "clear":
+		if err := clearCache(); err != nil {
+			return caddy.ExitCodeFailedQuit, err
+		}
+		log.Println("cache cleared.")
+
+	default:
+		return caddy.ExitCodeFailedQuit, fmt.Errorf("unknown cache action '%s', expected list, prune or clear", action)
+	}
+
+	return 0, nil
+}
+
+func runDocs(args []string) (int, error) {
+	if len(args) != 2 {
+		return caddy.ExitCodeFailedQuit, fmt.Errorf("usage: %s docs export|import <file>", commandName)
+	}
+	action, file := args[0], args[1]
+
+	switch action {
+	case "export":
+		ctx, cancel := interruptContext()
+		defer cancel()
+
+		docs, err := docSource().LoadDocs(ctx)
+		if err != nil {
+			return caddy.ExitCodeFailedQuit, err
+		}
+		if err := writeBundle(docs, file); err != nil {
+			return caddy.ExitCodeFailedQuit, err
+		}
+		log.Println(file, "written.")
+
+	case "import":
+		n, err := importBundle(file)
+		if err != nil {
+			return caddy.ExitCodeFailedQuit, err
+		}
+		log.Println(n, "docs imported.")
+
+	default:
+		return caddy.ExitCodeFailedQuit, fmt.Errorf("unknown docs action '%s', expected export or import", action)
+	}
+
+	return 0, nil
+}
+
+func runValidate(args []string) (int, error) {
+	fs := flag.NewFlagSet(commandName+" validate", flag.ExitOnError)
+	adapter := fs.String("adapter", "", "Name of config adapter to apply")
+	strict := fs.Bool("strict", false, "Reject unknown properties")
+	if err := fs.Parse(args); err != nil {
+		return caddy.ExitCodeFailedQuit, err
+	}
+	if fs.NArg() != 1 {
+		return caddy.ExitCodeFailedQuit, fmt.Errorf("usage: %s validate [--adapter <name>] [--strict] <file>", commandName)
+	}
+	file := fs.Arg(0)
+
+	body, err := ioutil.ReadFile(file)
+	if err != nil {
+		return caddy.ExitCodeFailedQuit, err
+	}
+
+	if *adapter != "" {
+		cfgAdapter := caddyconfig.GetAdapter(*adapter)
+		if cfgAdapter == nil {
+			return caddy.ExitCodeFailedQuit, fmt.Errorf("unrecognized config adapter: %s", *adapter)
+		}
+		adapted, warnings, err := cfgAdapter.Adapt(body, map[string]interface{}{"filename": file})
+		if err != nil {
+			return caddy.ExitCodeFailedQuit, err
+		}
+		for _, warn := range warnings {
+			log.Println("adapter warning:", warn.String())
+		}
+		body = adapted
+		// positions refer to the adapted JSON, report paths only
+		file = file + " (adapted)"
+	}
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+
+	schema, err := Generator{}.Generate(ctx)
+	if err != nil {
+		return caddy.ExitCodeFailedQuit, err
+	}
+
+	validate := schema.Validate
+	if *strict {
+		validate = schema.ValidateStrict
+	}
+	errs, err := validate(body)
+	if err != nil {
+		return caddy.ExitCodeFailedQuit, fmt.Errorf("%s: %v", file, err)
+	}
+	for _, e := range errs {
+		if *adapter != "" {
+			e.Line, e.Column = 0, 0
+		}
+		fmt.Printf("%s:%v\n", file, e)
+	}
+	if len(errs) > 0 {
+		return caddy.ExitCodeFailedQuit, fmt.Errorf("%s: %d validation errors", file, len(errs))
+	}
+
+	log.Println(file, "is valid.")
+	return 0, nil
+}
+
+// splitList splits a comma separated list, discarding empty entries.
+func splitList(list string) []string {
+	var entries []string
+	for _, entry := range strings.Split(list, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
@@ -1,92 +1,191 @@
 package jsonschema
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
-	"os"
-	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// apiDocsURL is the base URL of the caddyserver.com config docs API.
+const apiDocsURL = "https://caddyserver.com/api/docs/config/"
 
-	"github.com/caddyserver/caddy/v2"
+// defaults for WebDocs
+const (
+	defaultConcurrency = 8
+	defaultRetries     = 3
+	retryBackoff       = 500 * time.Millisecond
 )
 
-func loadDoc() error {
-	if err := loadRootDoc(); err != nil {
-		return err
+// defaultClient is the HTTP client shared by all requests to
+// caddyserver.com if WebDocs.Client is not set.
+var defaultClient = &http.Client{
+	Timeout: 30 * time.Second,
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 20 * time.Second,
+		MaxIdleConnsPerHost:   defaultConcurrency,
+	},
+}
+
+var errOffline = errors.New("docs not cached, offline")
+
+var _ DocSource = WebDocs{}
+
+// WebDocs is a DocSource that retrieves documentation from the
+// caddyserver.com API. Responses are cached locally.
+type WebDocs struct {
+	// DiscardCache discards the local cache and fetches the
+	// latest API docs.
+	DiscardCache bool
+
+	// CacheTTL is the duration after which cached docs are
+	// revalidated with caddyserver.com. Zero never expires.
+	CacheTTL time.Duration
+
+	// Concurrency is the maximum number of concurrent requests.
+	// Defaults to 8.
+	Concurrency int
+
+	// Retries is the number of times a failed request is retried
+	// with exponential backoff. Zero disables retries, negative
+	// values use the default of 3.
+	Retries int
+
+	// Client is the HTTP client for requests. Defaults to a
+	// client with timeouts.
+	Client *http.Client
+
+	// Offline only reads docs from the local cache, regardless of
+	// CacheTTL. Docs missing from the cache are not fetched.
+	Offline bool
+}
+
+// LoadDocs implements DocSource.
+func (w WebDocs) LoadDocs(ctx context.Context) (*Docs, error) {
+	docs := &Docs{
+		Modules: map[string]*DocAPIResp{},
+		Errors:  map[string]error{},
 	}
+	f := &docFetcher{WebDocs: w}
 
-	if err := fetchAllDocumentedModules(); err != nil {
-		return err
+	if err := f.loadRootDoc(ctx, docs); err != nil {
+		return nil, err
 	}
 
-	if err := fetchAllModuleDocs(); err != nil {
-		return err
+	if err := f.fetchAllDocumentedModules(ctx, docs); err != nil {
+		return nil, err
 	}
 
-	return nil
+	if err := f.fetchAllModuleDocs(ctx, docs); err != nil {
+		return nil, err
+	}
+
+	f.progress.log()
+	return docs, nil
+}
+
+// docFetcher fetches docs from caddyserver.com for a single LoadDocs
+// call.
+type docFetcher struct {
+	WebDocs
+	progress fetchProgress
+}
+
+// fetchProgress keeps count of fetched docs. The counters are updated
+// atomically by concurrent fetches.
+type fetchProgress struct {
+	cached, fetched, revalidated, failed int64
+}
+
+func (p *fetchProgress) log() {
+	log.Printf("docs: %d cached, %d fetched, %d revalidated, %d failed.",
+		atomic.LoadInt64(&p.cached),
+		atomic.LoadInt64(&p.fetched),
+		atomic.LoadInt64(&p.revalidated),
+		atomic.LoadInt64(&p.failed),
+	)
 }
 
 // loadRootDoc loads the documentation for the root config structure.
-func loadRootDoc() error {
-	b, err := fetchConfigDoc("")
+func (f *docFetcher) loadRootDoc(ctx context.Context, docs *Docs) error {
+	b, err := f.fetchConfigDoc(ctx, "")
 	if err != nil {
 		return err
 	}
-	if err := json.Unmarshal(b, &rootDocAPIResp); err != nil {
+	root, err := parseDoc(b)
+	if err != nil {
 		return err
 	}
-	if rootDocAPIResp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code %d from caddyserver.com", rootDocAPIResp.StatusCode)
-	}
+	docs.Root = *root
 	return nil
 }
 
-// fetchAllDocumentedModules() fetches and populate flatCaddyDocMap
-// with all available documented modules.
-func fetchAllDocumentedModules() error {
-	// website json doc has repeated modules
-	// prevent cycle
-	visited := map[string]struct{}{}
+// validDoc reports if b is a valid API doc.
+func validDoc(b []byte) bool {
+	_, err := parseDoc(b)
+	return err == nil
+}
+
+// parseDoc parses the API response b. An error is returned if b is not
+// a valid API doc with a successful status code.
+func parseDoc(b []byte) (*DocAPIResp, error) {
+	var doc DocAPIResp
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("invalid API doc: %v", err)
+	}
+	if doc.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d from caddyserver.com", doc.StatusCode)
+	}
+	return &doc, nil
+}
 
+// fetchAllDocumentedModules() fetches and populate docs.Modules
+// with all available documented modules.
+func (f *docFetcher) fetchAllDocumentedModules(ctx context.Context, docs *Docs) error {
 	// top level namespaces
-	for _, namespace := range rootDocAPIResp.Result.Namespaces[""] {
-		b, err := fetchNamespaceDoc(namespace.Name)
-		if err != nil {
-			fmt.Println("error fetching namespace", namespace.Name, ":", err)
-			continue
-		}
-		var tmp DocAPIResp
-		if err := json.Unmarshal(b, &tmp); err != nil {
-			fmt.Println("error unmarshaling namespace", namespace.Name, ":", err)
+	var namespaces []string
+	for _, namespace := range docs.Root.Result.Namespaces[""] {
+		namespaces = append(namespaces, namespace.Name)
+	}
+
+	results, err := f.fetchNamespaceDocs(ctx, docs, namespaces)
+	if err != nil {
+		return err
+	}
+
+	for _, namespace := range namespaces {
+		tmp, ok := results[namespace]
+		if !ok {
 			continue
 		}
-		if tmp.StatusCode != http.StatusOK {
-			return fmt.Errorf("unexpected status code %d from caddyserver.com", rootDocAPIResp.StatusCode)
-		}
 
-		flatCaddyDocMap[namespace.Name] = &tmp
+		docs.Modules[namespace] = tmp
 
 		// sub namespaces
+		// website json doc has repeated modules, the map
+		// prevents duplicates.
 		for ns, list := range tmp.Result.Namespaces {
 			if ns == "" {
 				// avoid top level
 				continue
 			}
 			for _, m := range list {
-				modulePath := m.Name
-				if ns != "" {
-					modulePath = ns + "." + m.Name
+				modulePath := ns + "." + m.Name
+				if _, ok := docs.Modules[modulePath]; !ok {
+					docs.Modules[modulePath] = nil
 				}
-				// check if visited
-				if _, ok := visited[modulePath]; ok {
-					continue
-				}
-				// mark visited
-				visited[modulePath] = struct{}{}
-
-				flatCaddyDocMap[modulePath] = nil
 			}
 		}
 
@@ -94,90 +193,230 @@ func fetchAllDocumentedModules() error {
 	return nil
 }
 
-// fetchJSONModuleDocs fetches docs for all available modules.
-func fetchAllModuleDocs() error {
-	for ns, doc := range flatCaddyDocMap {
-		if doc != nil {
-			continue
+// fetchAllModuleDocs fetches docs for all available modules.
+func (f *docFetcher) fetchAllModuleDocs(ctx context.Context, docs *Docs) error {
+	var namespaces []string
+	for ns, doc := range docs.Modules {
+		if doc == nil {
+			namespaces = append(namespaces, ns)
 		}
+	}
 
-		b, err := fetchNamespaceDoc(ns)
-		if err != nil {
-			fmt.Println("error fetching namespace", ns, ":", err)
-			continue
-		}
-		var tmp DocAPIResp
-		if err := json.Unmarshal(b, &tmp); err != nil {
-			fmt.Println("error unmarshaling namespace", ns, ":", err)
-			continue
-		}
+	results, err := f.fetchNamespaceDocs(ctx, docs, namespaces)
+	if err != nil {
+		return err
+	}
 
-		flatCaddyDocMap[ns] = &tmp
+	for _, ns := range namespaces {
+		if doc, ok := results[ns]; ok {
+			docs.Modules[ns] = doc
+		} else {
+			// failed, not documented
+			delete(docs.Modules, ns)
+		}
 	}
 
 	return nil
 }
 
+// fetchNamespaceDocs fetches the JSON docs for namespaces concurrently,
+// with at most Concurrency requests at a time.
+// Failed namespaces are omitted from the result and their errors are
+// recorded in docs.Errors. An error is only returned if ctx is done.
+func (f *docFetcher) fetchNamespaceDocs(ctx context.Context, docs *Docs, namespaces []string) (map[string]*DocAPIResp, error) {
+	workers := f.Concurrency
+	if workers <= 0 {
+		workers = defaultConcurrency
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = map[string]*DocAPIResp{}
+		queue   = make(chan string)
+	)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ns := range queue {
+				var tmp *DocAPIResp
+				b, err := f.fetchNamespaceDoc(ctx, ns)
+				if err == nil {
+					tmp, err = parseDoc(b)
+				}
+
+				mu.Lock()
+				if err != nil {
+					docs.Errors[ns] = err
+				} else {
+					results[ns] = tmp
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+dispatch:
+	for _, ns := range namespaces {
+		select {
+		case queue <- ns:
+		case <-ctx.Done():
+			// cancelled, stop dispatching
+			break dispatch
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	return results, ctx.Err()
+}
+
 // fetchNamespaceDoc fetches the JSON doc for namespace
-func fetchNamespaceDoc(namespace string) ([]byte, error) {
-	return fetchConfigDoc("apps/" + namespace)
+func (f *docFetcher) fetchNamespaceDoc(ctx context.Context, namespace string) ([]byte, error) {
+	return f.fetchConfigDoc(ctx, "apps/"+namespace)
 }
 
 // fetchConfigDoc fetchs the JSON doc for config. e.g. admin, logging
-func fetchConfigDoc(config string) ([]byte, error) {
+func (f *docFetcher) fetchConfigDoc(ctx context.Context, config string) ([]byte, error) {
 	// try local cache first
-	cache, err := cacheFile(config)
-	if err == nil {
-		b, err := ioutil.ReadFile(cache)
-		if err == nil {
-			return b, nil
+	var (
+		cached []byte
+		meta   cacheMeta
+	)
+	if !f.DiscardCache {
+		// discard invalid docs cached by older versions
+		if b, m, err := readCache(config); err == nil && validDoc(b) {
+			if !m.expired(f.CacheTTL) {
+				atomic.AddInt64(&f.progress.cached, 1)
+				return b, nil
+			}
+			cached, meta = b, m
+		}
+	}
+
+	if f.Offline {
+		if cached != nil {
+			atomic.AddInt64(&f.progress.cached, 1)
+			return cached, nil
 		}
+		atomic.AddInt64(&f.progress.failed, 1)
+		return nil, errOffline
 	}
 
-	apiURL := "https://caddyserver.com/api/docs/config/" + config
+	b, err := f.fetchWithRetry(ctx, config, cached, meta)
+	if err != nil && cached != nil && ctx.Err() == nil {
+		// fallback to expired cache e.g. when offline
+		atomic.AddInt64(&f.progress.cached, 1)
+		return cached, nil
+	}
+	if err != nil {
+		atomic.AddInt64(&f.progress.failed, 1)
+		return nil, err
+	}
+	return b, nil
+}
 
+// fetchWithRetry fetches the JSON doc for config, retrying failed
+// requests with exponential backoff.
+func (f *docFetcher) fetchWithRetry(ctx context.Context, config string, cached []byte, meta cacheMeta) ([]byte, error) {
+	retries := f.Retries
+	if retries < 0 {
+		retries = defaultRetries
+	}
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		var (
+			b         []byte
+			retryable bool
+		)
+		b, retryable, err = f.fetch(ctx, config, cached, meta)
+		if err == nil || !retryable || attempt >= retries {
+			return b, err
+		}
+
+		select {
+		case <-time.After(retryBackoff << uint(attempt)):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// fetch makes a single request for the JSON doc for config. If cached is
+// not nil, the request is conditional and cached is returned if still valid.
+// It reports if a failed request can be retried.
+func (f *docFetcher) fetch(ctx context.Context, config string, cached []byte, meta cacheMeta) ([]byte, bool, error) {
 	label := config
 	if config == "" {
 		label = "root config"
 	}
 
-	if err == errCacheDisabled {
-		log.Println("discarding cache for", label+".")
-	} else {
-		log.Println("cached docs not found for", label+".")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiDocsURL+config, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	// conditional request for expired cache
+	if cached != nil {
+		if meta.ETag != "" {
+			req.Header.Set("If-None-Match", meta.ETag)
+		}
+		if meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", meta.LastModified)
+		}
 	}
-	log.Println("fetching", apiURL, "...")
 
-	resp, err := http.Get(apiURL)
-	if err != nil {
-		return nil, err
+	client := f.Client
+	if client == nil {
+		client = defaultClient
 	}
-	b, err := ioutil.ReadAll(resp.Body)
+	resp, err := client.Do(req)
 	if err != nil {
-		return nil, err
+		// network errors are retryable, unless cancelled
+		return nil, ctx.Err() == nil, err
 	}
-	resp.Body.Close()
+	defer resp.Body.Close()
 
-	// cache file
-	defer ioutil.WriteFile(cache, b, 0600)
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		// still valid, renew cache
+		meta.FetchedAt = time.Now()
+		if err := writeCache(config, nil, meta); err != nil {
+			log.Println("error caching docs for", label, ":", err)
+		}
+		atomic.AddInt64(&f.progress.revalidated, 1)
+		return cached, false, nil
+	}
 
-	log.Println()
-	return b, nil
-}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+		return nil, true, fmt.Errorf("unexpected status code %d from caddyserver.com", resp.StatusCode)
+	}
 
-var errCacheDisabled = errors.New("cache disabled")
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("unexpected status code %d from caddyserver.com", resp.StatusCode)
+	}
 
-// cacheFile returns the filesystem path to cached API doc
-// for namespace
-func cacheFile(namespace string) (string, error) {
-	if config.DiscardCache {
-		return "", errCacheDisabled
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, ctx.Err() == nil, err
+	}
+	if _, err := parseDoc(b); err != nil {
+		return nil, false, err
 	}
 
-	fileName := "docs.json"
-	dir := filepath.Join(caddy.AppDataDir(), "json_schema", namespace)
-	if err := os.MkdirAll(dir, 0700); err != nil {
-		return "", err
+	// cache file, only valid docs get here
+	if !f.DiscardCache {
+		meta := cacheMeta{
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			FetchedAt:    time.Now(),
+		}
+		if err := writeCache(config, b, meta); err != nil {
+			log.Println("error caching docs for", label, ":", err)
+		}
 	}
-	return filepath.Join(dir, fileName), nil
+
+	atomic.AddInt64(&f.progress.fetched, 1)
+	return b, false, nil
 }
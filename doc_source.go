@@ -0,0 +1,364 @@
+package jsonschema
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/doc"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime/debug"
+	"strings"
+	"unicode"
+
+	"github.com/caddyserver/caddy/v2"
+)
+
+var _ DocSource = SourceDocs{}
+
+// SourceDocs is a DocSource that generates documentation from the
+// Go doc comments of the module sources in the Go module cache or
+// GOPATH. It requires no network access and also documents modules
+// that are not registered on caddyserver.com.
+type SourceDocs struct{}
+
+// LoadDocs implements DocSource.
+func (SourceDocs) LoadDocs(ctx context.Context) (*Docs, error) {
+	b := newSourceDocBuilder()
+	docs := &Docs{
+		Modules: map[string]*DocAPIResp{},
+		Errors:  map[string]error{},
+	}
+
+	docs.Root.StatusCode = http.StatusOK
+	docs.Root.Result.Structure = b.structure(reflect.TypeOf(caddy.Config{}))
+
+	for _, mod := range caddy.Modules() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		info, err := caddy.GetModule(mod)
+		if err != nil {
+			return nil, err
+		}
+
+		t := reflect.TypeOf(info.New())
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if b.docType(t) == nil {
+			docs.Errors[mod] = fmt.Errorf("sources not found for %s.%s", t.PkgPath(), t.Name())
+			continue
+		}
+
+		resp := &DocAPIResp{StatusCode: http.StatusOK}
+		resp.Result.Structure = b.structure(t)
+		docs.Modules[mod] = resp
+	}
+
+	return docs, nil
+}
+
+// sourceDocBuilder builds DocStruct trees from Go types and the doc
+// comments of their package sources.
+type sourceDocBuilder struct {
+	deps     []*debug.Module
+	packages map[string]*doc.Package // nil value for unavailable packages
+	visiting map[reflect.Type]bool
+}
+
+func newSourceDocBuilder() *sourceDocBuilder {
+	b := &sourceDocBuilder{
+		packages: map[string]*doc.Package{},
+		visiting: map[reflect.Type]bool{},
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		b.deps = append(b.deps, &info.Main)
+		b.deps = append(b.deps, info.Deps...)
+	}
+	return b
+}
+
+// structure returns the documentation structure for t.
+func (b *sourceDocBuilder) structure(t reflect.Type) *DocStruct {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	d := &DocStruct{}
+	if t.Name() != "" && t.PkgPath() != "" {
+		d.Package = t.PkgPath() + "." + t.Name()
+		d.Doc = b.typeDoc(t)
+	}
+
+	// prevent infinite recursion for self referencing types
+	if b.visiting[t] {
+		return d
+	}
+	b.visiting[t] = true
+	defer delete(b.visiting, t)
+
+	switch t.Kind() {
+	case reflect.Struct:
+		d.Type = "struct"
+		fieldDocs := b.fieldDocs(t)
+		for _, ff := range jsonFields(t) {
+			field := &DocStruct{
+				Key: ff.name,
+				Doc: fieldDocs[ff.Name],
+			}
+			if caddyTag, ok := ff.Tag.Lookup("caddy"); ok {
+				namespace, inlineKey := "", ""
+				for _, opt := range strings.Fields(caddyTag) {
+					if strings.HasPrefix(opt, "namespace=") {
+						namespace = strings.TrimPrefix(opt, "namespace=")
+					}
+					if strings.HasPrefix(opt, "inline_key=") {
+						inlineKey = strings.TrimPrefix(opt, "inline_key=")
+					}
+				}
+				field.Value = loaderStructure(ff.Type, namespace, inlineKey)
+			} else {
+				field.Value = b.structure(ff.Type)
+			}
+			// field docs take precedence over type docs, including
+			// array and map elements, as with the caddyserver.com API.
+			if field.Doc != "" {
+				for v := field.Value; v != nil; v = v.Elems {
+					v.Doc = field.Doc
+				}
+			}
+			d.StructFields = append(d.StructFields, field)
+		}
+
+	case reflect.Slice, reflect.Array:
+		d.Type = "array"
+		d.Elems = b.structure(t.Elem())
+
+	case reflect.Map:
+		d.Type = "map"
+		d.MapKeys.Type = t.Key().Kind().String()
+		d.Elems = b.structure(t.Elem())
+
+	default:
+		d.Type = t.Kind().String()
+	}
+
+	return d
+}
+
+// loaderStructure returns the documentation structure for a module
+// loader field of type t.
+func loaderStructure(t reflect.Type, namespace, inlineKey string) *DocStruct {
+	switch {
+	case t == reflect.TypeOf(json.RawMessage{}):
+		return &DocStruct{Type: "module", Namespace: namespace, InlineKey: inlineKey}
+	case t.Kind() == reflect.Map && t.Elem() == reflect.TypeOf(json.RawMessage{}):
+		return &DocStruct{Type: "module_map", Namespace: namespace}
+	case t.Kind() == reflect.Slice:
+		return &DocStruct{Type: "array", Elems: loaderStructure(t.Elem(), namespace, inlineKey)}
+	case t.Kind() == reflect.Map:
+		return &DocStruct{Type: "map", Elems: loaderStructure(t.Elem(), namespace, inlineKey)}
+	}
+	return &DocStruct{Type: "module", Namespace: namespace, InlineKey: inlineKey}
+}
+
+// typeDoc returns the doc comment for the named type t.
+func (b *sourceDocBuilder) typeDoc(t reflect.Type) string {
+	if typ := b.docType(t); typ != nil {
+		return typ.Doc
+	}
+	return ""
+}
+
+// fieldDocs returns the doc comments of the fields of struct t,
+// including fields of embedded structs, mapped by Go field name.
+func (b *sourceDocBuilder) fieldDocs(t reflect.Type) map[string]string {
+	docs := map[string]string{}
+
+	for i := 0; i < t.NumField(); i++ {
+		if f := t.Field(i); f.Anonymous {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for name, d := range b.fieldDocs(ft) {
+					docs[name] = d
+				}
+			}
+		}
+	}
+
+	typ := b.docType(t)
+	if typ == nil {
+		return docs
+	}
+	for _, spec := range typ.Decl.Specs {
+		ts, ok := spec.(*ast.TypeSpec)
+		if !ok || ts.Name.Name != t.Name() {
+			continue
+		}
+		st, ok := ts.Type.(*ast.StructType)
+		if !ok {
+			continue
+		}
+		for _, field := range st.Fields.List {
+			text := field.Doc.Text()
+			if text == "" {
+				text = field.Comment.Text()
+			}
+			for _, name := range field.Names {
+				docs[name.Name] = text
+			}
+		}
+	}
+	return docs
+}
+
+// docType returns the go/doc type for the named type t.
+func (b *sourceDocBuilder) docType(t reflect.Type) *doc.Type {
+	if t.Name() == "" || t.PkgPath() == "" {
+		return nil
+	}
+	pkg := b.docPackage(t.PkgPath())
+	if pkg == nil {
+		return nil
+	}
+	for _, typ := range pkg.Types {
+		if typ.Name == t.Name() {
+			return typ
+		}
+	}
+	return nil
+}
+
+// docPackage parses and returns the documentation for the package
+// with import path pkgPath. It returns nil if the package sources
+// cannot be found.
+func (b *sourceDocBuilder) docPackage(pkgPath string) *doc.Package {
+	if pkg, ok := b.packages[pkgPath]; ok {
+		return pkg
+	}
+
+	var pkg *doc.Package
+	for _, dir := range b.packageDirs(pkgPath) {
+		if pkg = parsePackageDoc(dir, pkgPath); pkg != nil {
+			break
+		}
+	}
+
+	b.packages[pkgPath] = pkg
+	return pkg
+}
+
+// packageDirs returns the candidate source directories of the package
+// with import path pkgPath.
+func (b *sourceDocBuilder) packageDirs(pkgPath string) []string {
+	var dirs []string
+
+	// Go module cache, using the module versions in the current build
+	var mod *debug.Module
+	for _, dep := range b.deps {
+		if dep.Path != pkgPath && !strings.HasPrefix(pkgPath, dep.Path+"/") {
+			continue
+		}
+		// longest prefix is the most specific module
+		if mod == nil || len(dep.Path) > len(mod.Path) {
+			mod = dep
+		}
+	}
+	if mod != nil {
+		subPath := strings.TrimPrefix(pkgPath, mod.Path)
+		switch {
+		case mod.Replace != nil && mod.Replace.Version == "":
+			// replaced with a local directory
+			dirs = append(dirs, filepath.Join(mod.Replace.Path, subPath))
+		case mod.Replace != nil:
+			dirs = append(dirs, filepath.Join(modCacheDir(), escapeModulePath(mod.Replace.Path)+"@"+mod.Replace.Version, subPath))
+		case mod.Version != "" && mod.Version != "(devel)":
+			dirs = append(dirs, filepath.Join(modCacheDir(), escapeModulePath(mod.Path)+"@"+mod.Version, subPath))
+		}
+	}
+
+	// GOPATH
+	for _, gopath := range filepath.SplitList(build.Default.GOPATH) {
+		dirs = append(dirs, filepath.Join(gopath, "src", pkgPath))
+	}
+
+	return dirs
+}
+
+// modCacheDir returns the Go module cache directory.
+func modCacheDir() string {
+	if dir := os.Getenv("GOMODCACHE"); dir != "" {
+		return dir
+	}
+	gopath := filepath.SplitList(build.Default.GOPATH)
+	if len(gopath) == 0 {
+		return ""
+	}
+	return filepath.Join(gopath[0], "pkg", "mod")
+}
+
+// escapeModulePath escapes the module path for the module cache.
+// Uppercase letters are replaced with an exclamation mark followed
+// by the lowercase letter.
+func escapeModulePath(path string) string {
+	var b strings.Builder
+	for _, r := range path {
+		if unicode.IsUpper(r) {
+			b.WriteRune('!')
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// parsePackageDoc parses the Go sources in dir and returns the package
+// documentation. It returns nil if dir contains no Go sources.
+func parsePackageDoc(dir, pkgPath string) *doc.Package {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	fset := token.NewFileSet()
+	files := map[string][]*ast.File{} // grouped by package name
+	pkgName := ""
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			continue
+		}
+		name = file.Name.Name
+		files[name] = append(files[name], file)
+		// the package is the most common package name, in case of
+		// ignored files e.g. code generators.
+		if len(files[name]) > len(files[pkgName]) {
+			pkgName = name
+		}
+	}
+	if len(files[pkgName]) == 0 {
+		return nil
+	}
+
+	pkg, err := doc.NewFromFiles(fset, files[pkgName], pkgPath, doc.AllDecls)
+	if err != nil {
+		return nil
+	}
+	return pkg
+}
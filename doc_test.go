@@ -0,0 +1,42 @@
+package jsonschema
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// roundTripFunc is an http.RoundTripper calling itself.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestFetchRetries(t *testing.T) {
+	tests := []struct {
+		retries  int
+		requests int
+	}{
+		{0, 1},
+		{1, 2},
+	}
+	for _, tt := range tests {
+		requests := 0
+		client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			requests++
+			return &http.Response{
+				StatusCode: http.StatusServiceUnavailable,
+				Body:       ioutil.NopCloser(strings.NewReader("")),
+				Request:    r,
+			}, nil
+		})}
+		f := &docFetcher{WebDocs: WebDocs{Retries: tt.retries, Client: client, DiscardCache: true}}
+		if _, err := f.fetchWithRetry(context.Background(), "", nil, cacheMeta{}); err == nil {
+			t.Errorf("retries %d: got no error", tt.retries)
+		}
+		if requests != tt.requests {
+			t.Errorf("retries %d: got %d requests, want %d", tt.retries, requests, tt.requests)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package jsonschema
+
+import (
+	"regexp"
+	"strings"
+)
+
+// enumOverrides are the known values of string fields, mapped by
+// "<package path>.<type name>/<property>". They are applied to the
+// fields of the Go types, whether documented or not, and disable the
+// harvesting from the docs; nil only disables the harvesting.
+var enumOverrides = map[string][]string{
+	caddyPkg + "/modules/caddyhttp/reverseproxy.HTTPTransport/versions": {"1.1", "2", "h2c"},
+	caddyPkg + "/modules/caddytls.AutomationPolicy/key_type":            {"ed25519", "p256", "p384", "rsa2048", "rsa4096"},
+	caddyPkg + "/modules/caddytls.ClientAuthentication/mode":            {"request", "require", "verify_if_given", "require_and_verify"},
+	caddyPkg + "/modules/caddytls.ConnectionPolicy/protocol_min":        {"tls1.2", "tls1.3"},
+	caddyPkg + "/modules/caddytls.ConnectionPolicy/protocol_max":        {"tls1.2", "tls1.3"},
+	caddyPkg + "/modules/logging.ConsoleEncoder/duration_format":        {"seconds", "nano", "string"},
+	caddyPkg + "/modules/logging.ConsoleEncoder/level_format":           {"lower", "upper", "color"},
+	caddyPkg + "/modules/logging.JSONEncoder/duration_format":           {"seconds", "nano", "string"},
+	caddyPkg + "/modules/logging.JSONEncoder/level_format":              {"lower", "upper", "color"},
+
+	// levels are case insensitive and may be placeholders
+	caddyPkg + ".CustomLog/level": nil,
+}
+
+var (
+	// enumListPattern matches lists of values in docs e.g.
+	// "Possible levels: DEBUG, INFO, WARN, ERROR, PANIC, and FATAL".
+	// An empty list is followed by a table of values.
+	enumListPattern = regexp.MustCompile(`(?im)\b(?:possible|supported|allowed|valid|accepted) (?:values|levels|modes|options)(?: are)?:[ \t]*(.*)$`)
+
+	// enumTablePattern matches rows of tables of values in docs e.g.
+	// "`require` | Require clients to present a certificate".
+	enumTablePattern = regexp.MustCompile("(?m)^\\s*`([^`]+)`\\s*\\|")
+
+	// enumQuotedPattern matches quoted values in docs e.g. `Can be "pem".`
+	enumQuotedPattern = regexp.MustCompile(`(?i)\bcan be ((?:"[^"\s]+"(?:,\s*|\s+or\s+)?)+)`)
+
+	// enumValuePattern matches a single value.
+	enumValuePattern = regexp.MustCompile(`^[\w.\-/]+$`)
+
+	// enumSeparatorPattern matches the separators of listed values.
+	enumSeparatorPattern = regexp.MustCompile(`,|\band\b|\bor\b`)
+
+	// enumQuotedValuePattern matches a quoted value.
+	enumQuotedValuePattern = regexp.MustCompile(`"([^"]+)"`)
+)
+
+// docEnum returns the known values of the property of the struct type
+// typeName harvested from the doc of the property. Nil is returned for
+// properties in enumOverrides, their values are set from the Go type.
+func docEnum(typeName, property, doc string) []string {
+	if _, ok := enumOverrides[typeName+"/"+property]; ok {
+		return nil
+	}
+
+	if m := enumListPattern.FindStringSubmatch(doc); m != nil {
+		list := strings.TrimSuffix(strings.TrimSpace(m[1]), ".")
+		if list == "" {
+			var values []string
+			for _, row := range enumTablePattern.FindAllStringSubmatch(doc, -1) {
+				values = append(values, row[1])
+			}
+			return enumValues(values)
+		}
+		return enumValues(enumSeparatorPattern.Split(list, -1))
+	}
+
+	if m := enumQuotedPattern.FindStringSubmatch(doc); m != nil {
+		return enumValues(enumQuotedValuePattern.FindAllString(m[1], -1))
+	}
+
+	return nil
+}
+
+// enumValues cleans up the harvested values. Nil is returned if any
+// value is not a single word.
+func enumValues(list []string) []string {
+	var values []string
+	for _, v := range list {
+		v = strings.Trim(strings.TrimSpace(v), "\"`'")
+		if v == "" {
+			continue
+		}
+		if !enumValuePattern.MatchString(v) {
+			return nil
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+// setEnum sets the known values of the string schema s, or of the items
+// of s if an array of strings. Existing enums are kept.
+func setEnum(s *Schema, values []string) {
+	if len(values) == 0 {
+		return
+	}
+	if s.Type == "array" && s.ArrayItems != nil {
+		s = s.ArrayItems
+	}
+	if s.Type == "string" && len(s.Enum) == 0 {
+		s.Enum = values
+	}
+}
@@ -0,0 +1,37 @@
+package jsonschema
+
+import (
+	"reflect"
+	"testing"
+
+	_ "github.com/caddyserver/caddy/v2/modules/logging"
+)
+
+func TestDocEnum(t *testing.T) {
+	tests := []struct {
+		typeName, property, doc string
+		want                    []string
+	}{
+		{"example.T", "level", "Possible levels: DEBUG, INFO, WARN, and ERROR.", []string{"DEBUG", "INFO", "WARN", "ERROR"}},
+		{"example.T", "mode", "The mode. Possible values:\n\n`a` | first\n`b` | second", []string{"a", "b"}},
+		{"example.T", "format", `The format. Can be "pem" or "der".`, []string{"pem", "der"}},
+		{"example.T", "name", "Possible values: any name you like.", nil},
+		{caddyPkg + "/modules/logging.JSONEncoder", "level_format", "Possible values: lower or upper.", nil},
+	}
+	for _, tt := range tests {
+		if got := docEnum(tt.typeName, tt.property, tt.doc); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s/%s: got %v, want %v", tt.typeName, tt.property, got, tt.want)
+		}
+	}
+}
+
+func TestEnumOverrides(t *testing.T) {
+	s := generateTest(t, Generator{}, "caddy.logging.encoders.")
+	got := s.Definitions["caddy.logging.encoders.json"].Properties["level_format"].Enum
+	if want := []string{"lower", "upper", "color"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got level_format enum %v, want %v", got, want)
+	}
+	if got := s.Definitions["caddy.logging.encoders.json"].Properties["time_format"].Enum; len(got) > 0 {
+		t.Errorf("got time_format enum %v", got)
+	}
+}
@@ -0,0 +1,210 @@
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig"
+)
+
+// Examples configures the examples attached to the schema. Examples
+// are taken from JSON blocks in the docs, from the adaptation of known
+// Caddyfile snippets and from Dir.
+type Examples struct {
+	// Dir is a directory of user-supplied examples. Examples for a
+	// module are read from a file named "<module ID>.json" containing
+	// a JSON array of examples. If empty, there are no user-supplied
+	// examples.
+	Dir string
+}
+
+// caddyfileSnippets are the Caddyfile directives adapted for examples
+// of modules, mapped by module ID.
+var caddyfileSnippets = map[string][]string{
+	"http.handlers.encode":          {"encode zstd gzip"},
+	"http.handlers.file_server":     {"file_server", "file_server browse"},
+	"http.handlers.headers":         {"header Cache-Control max-age=3600", "header -Server"},
+	"http.handlers.request_body":    {"request_body {\n\tmax_size 10MB\n}"},
+	"http.handlers.reverse_proxy":   {"reverse_proxy localhost:8080", "reverse_proxy localhost:8080 localhost:8081 {\n\tlb_policy round_robin\n\thealth_uri /health\n}"},
+	"http.handlers.rewrite":         {"rewrite * /index.html"},
+	"http.handlers.static_response": {`respond "Hello, world!"`},
+	"http.handlers.templates":       {"templates"},
+}
+
+// load reads the user-supplied examples, mapped by module ID.
+func (e *Examples) load() (map[string][]json.RawMessage, error) {
+	examples := map[string][]json.RawMessage{}
+	if e == nil || e.Dir == "" {
+		return examples, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(e.Dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var list []json.RawMessage
+		if err := json.Unmarshal(b, &list); err != nil {
+			return nil, fmt.Errorf("examples %s: expected a JSON array of examples: %v", file, err)
+		}
+		moduleID := strings.TrimSuffix(filepath.Base(file), ".json")
+		examples[moduleID] = list
+	}
+	return examples, nil
+}
+
+// caddyfileExamples returns the examples of the module adapted from
+// caddyfileSnippets. Snippets are adapted within a site block and the
+// module is looked up by its inline key in the result. Snippets that
+// fail to adapt are skipped.
+func caddyfileExamples(moduleID, inlineKey string) []json.RawMessage {
+	snippets := caddyfileSnippets[moduleID]
+	adapter := caddyconfig.GetAdapter("caddyfile")
+	if len(snippets) == 0 || adapter == nil || inlineKey == "" {
+		return nil
+	}
+	name := moduleID[strings.LastIndex(moduleID, ".")+1:]
+
+	var examples []json.RawMessage
+	for _, snippet := range snippets {
+		adapted, _, err := adapter.Adapt([]byte(":80 {\n"+snippet+"\n}\n"), nil)
+		if err != nil {
+			continue
+		}
+		var cfg interface{}
+		if err := json.Unmarshal(adapted, &cfg); err != nil {
+			continue
+		}
+		if obj := findModule(cfg, inlineKey, name); obj != nil {
+			if b, err := json.Marshal(obj); err == nil {
+				examples = append(examples, b)
+			}
+		}
+	}
+	return examples
+}
+
+// findModule returns the first object in the decoded JSON value v with
+// the inline key set to the module name.
+func findModule(v interface{}, inlineKey, name string) map[string]interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if v[inlineKey] == name {
+			return v
+		}
+		for _, value := range v {
+			if obj := findModule(value, inlineKey, name); obj != nil {
+				return obj
+			}
+		}
+	case []interface{}:
+		for _, value := range v {
+			if obj := findModule(value, inlineKey, name); obj != nil {
+				return obj
+			}
+		}
+	}
+	return nil
+}
+
+// docExamples returns the JSON blocks in the doc text that match the
+// shape of s i.e. objects with properties of s or arrays. Blocks are
+// fenced with ``` or indented.
+func docExamples(s *Schema, doc string) []json.RawMessage {
+	var examples []json.RawMessage
+	for _, block := range codeBlocks(doc) {
+		block = strings.TrimSpace(block)
+		if !json.Valid([]byte(block)) {
+			continue
+		}
+		var v interface{}
+		if err := json.Unmarshal([]byte(block), &v); err != nil {
+			continue
+		}
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if len(v) == 0 || len(s.Properties) == 0 {
+				continue
+			}
+			for key := range v {
+				if _, ok := s.Properties[key]; !ok {
+					v = nil
+					break
+				}
+			}
+			if v == nil {
+				continue
+			}
+		case []interface{}:
+			if s.Type != "array" {
+				continue
+			}
+		default:
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, []byte(block)); err != nil {
+			continue
+		}
+		if strings.Trim(buf.String(), "[]{},") == "" {
+			// placeholder structure without values
+			continue
+		}
+		examples = append(examples, buf.Bytes())
+	}
+	return examples
+}
+
+// codeBlocks returns the code blocks in the doc text. Code blocks are
+// fenced with ``` or indented, and separated from text by blank lines.
+func codeBlocks(doc string) []string {
+	var blocks []string
+	var block []string
+	fenced, indented := false, false
+	flush := func() {
+		if len(block) > 0 {
+			blocks = append(blocks, strings.Join(block, "\n"))
+		}
+		block = nil
+		indented = false
+	}
+
+	prevBlank := true
+	for _, line := range strings.Split(doc, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "```"):
+			if fenced {
+				flush()
+			}
+			fenced = !fenced
+		case fenced:
+			block = append(block, line)
+		case trimmed != "" && (strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "  ")):
+			if indented || prevBlank {
+				indented = true
+				block = append(block, line)
+			}
+		case indented && trimmed == "":
+			block = append(block, line)
+		default:
+			if indented {
+				flush()
+			}
+		}
+		prevBlank = trimmed == ""
+	}
+	if indented {
+		flush()
+	}
+	return blocks
+}
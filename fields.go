@@ -0,0 +1,215 @@
+package jsonschema
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// jsonField is a struct field decoded from JSON, following the rules
+// of encoding/json.
+type jsonField struct {
+	reflect.StructField
+
+	// name is the JSON property name
+	name string
+
+	// tagged is set if the name is from the json struct tag
+	tagged bool
+
+	// omitEmpty and quoted are the omitempty and string options
+	omitEmpty bool
+	quoted    bool
+}
+
+// jsonFields returns the fields of the struct t decoded from JSON, in
+// field order. Like encoding/json, the fields of embedded structs
+// without json names are promoted, and fields with conflicting names
+// are resolved by depth and json tags; unresolved conflicts are
+// dropped. The Index of the fields is relative to t.
+func jsonFields(t reflect.Type) []jsonField {
+	type embedded struct {
+		t     reflect.Type
+		index []int
+	}
+
+	var fields []jsonField
+	visited := map[reflect.Type]bool{}
+
+	// count and nextCount are the number of times the embedded structs
+	// occur at the current and next depth. The fields of structs
+	// embedded more than once at a depth conflict with themselves.
+	var count, nextCount map[reflect.Type]int
+	for next := []embedded{{t: t}}; len(next) > 0; {
+		current := next
+		next = nil
+		count, nextCount = nextCount, map[reflect.Type]int{}
+		for _, e := range current {
+			if visited[e.t] {
+				continue
+			}
+			visited[e.t] = true
+
+			for i := 0; i < e.t.NumField(); i++ {
+				sf := e.t.Field(i)
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				exported := sf.PkgPath == ""
+				if sf.Anonymous {
+					if !exported && ft.Kind() != reflect.Struct {
+						// unexported embedded non-structs are ignored
+						continue
+					}
+				} else if !exported {
+					continue
+				}
+
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				name, opts := parseJSONTag(tag)
+				if !validJSONName(name) {
+					name = ""
+				}
+
+				index := make([]int, len(e.index)+1)
+				copy(index, e.index)
+				index[len(e.index)] = i
+
+				if name == "" && sf.Anonymous && ft.Kind() == reflect.Struct {
+					// promote the fields of embedded structs
+					nextCount[ft]++
+					if nextCount[ft] == 1 {
+						next = append(next, embedded{t: ft, index: index})
+					}
+					continue
+				}
+
+				field := jsonField{
+					StructField: sf,
+					name:        name,
+					tagged:      name != "",
+					omitEmpty:   opts.has("omitempty"),
+				}
+				if field.name == "" {
+					field.name = sf.Name
+				}
+				field.Index = index
+				if opts.has("string") {
+					switch ft.Kind() {
+					case reflect.Bool, reflect.String,
+						reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+						reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+						reflect.Float32, reflect.Float64:
+						field.quoted = true
+					}
+				}
+				fields = append(fields, field)
+				if count[e.t] > 1 {
+					// a duplicate for the conflict resolution
+					fields = append(fields, field)
+				}
+			}
+		}
+	}
+
+	// the dominant field of a name is the shallowest, then the tagged
+	sort.SliceStable(fields, func(i, j int) bool {
+		a, b := fields[i], fields[j]
+		if a.name != b.name {
+			return a.name < b.name
+		}
+		if len(a.Index) != len(b.Index) {
+			return len(a.Index) < len(b.Index)
+		}
+		return a.tagged && !b.tagged
+	})
+	var dominant []jsonField
+	for i := 0; i < len(fields); {
+		j := i + 1
+		for j < len(fields) && fields[j].name == fields[i].name {
+			j++
+		}
+		if j-i == 1 || len(fields[i].Index) < len(fields[i+1].Index) || fields[i].tagged != fields[i+1].tagged {
+			dominant = append(dominant, fields[i])
+		}
+		i = j
+	}
+
+	sort.Slice(dominant, func(i, j int) bool {
+		a, b := dominant[i].Index, dominant[j].Index
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	return dominant
+}
+
+// jsonTagOptions are the comma separated options of a json struct tag.
+type jsonTagOptions string
+
+// has reports if the options contain the option.
+func (o jsonTagOptions) has(option string) bool {
+	for _, opt := range strings.Split(string(o), ",") {
+		if opt == option {
+			return true
+		}
+	}
+	return false
+}
+
+// parseJSONTag splits the json struct tag into the name and options.
+func parseJSONTag(tag string) (string, jsonTagOptions) {
+	if i := strings.Index(tag, ","); i >= 0 {
+		return tag[:i], jsonTagOptions(tag[i+1:])
+	}
+	return tag, ""
+}
+
+// validJSONName reports if name is a valid json struct tag name.
+// encoding/json ignores invalid names.
+func validJSONName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", c):
+			// backslash and quote chars are reserved
+		case !unicode.IsLetter(c) && !unicode.IsDigit(c):
+			return false
+		}
+	}
+	return true
+}
+
+// quotedSchema returns the schema of the scalar type t encoded as a
+// string with the json string option.
+func quotedSchema(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	s := NewSchema()
+	s.setType("string")
+	switch t.Kind() {
+	case reflect.Bool:
+		s.Enum = []string{"true", "false"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s.Pattern = `^-?[0-9]+$`
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		s.Pattern = `^[0-9]+$`
+	case reflect.Float32, reflect.Float64:
+		s.Pattern = `^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`
+	case reflect.String:
+		// a JSON string within the string
+		s.Pattern = `^".*"$`
+	}
+	return s
+}
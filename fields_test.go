@@ -0,0 +1,112 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+type testFieldsA struct {
+	X int
+	Y int `json:"y"`
+}
+
+type testFieldsB struct {
+	X int
+}
+
+type testFieldsTagged struct {
+	X int `json:"X"`
+}
+
+type testFieldsWrapA struct{ testFieldsA }
+
+type testFieldsWrapB struct{ testFieldsA }
+
+type testFieldsLower struct {
+	Z int
+}
+
+func TestJSONFields(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+	}{
+		{"string option", struct {
+			A int     `json:"a,string"`
+			B bool    `json:"b,string"`
+			C []int   `json:"c,string"`
+			D *string `json:"d,string"`
+		}{D: new(string)}},
+		{"empty tag name", struct {
+			A int `json:",omitempty"`
+		}{A: 1}},
+		{"invalid tag name", struct {
+			A int `json:"\\"`
+			B int `json:"\"\""`
+			C int `json:"a-b"`
+		}{}},
+		{"ignored", struct {
+			A int `json:"-"`
+			B int `json:"-,"`
+			c int
+		}{}},
+		{"embedded pointer", struct {
+			*testFieldsA
+			Z int
+		}{testFieldsA: &testFieldsA{}}},
+		{"named embedded", struct {
+			testFieldsA `json:"a"`
+		}{}},
+		{"unexported embedded", struct {
+			testFieldsLower
+		}{}},
+		{"same depth conflict", struct {
+			testFieldsA
+			testFieldsB
+		}{}},
+		{"same type twice at same depth", struct {
+			testFieldsWrapA
+			testFieldsWrapB
+		}{}},
+		{"shallower dominates", struct {
+			testFieldsA
+			X string
+		}{}},
+		{"tagged dominates", struct {
+			testFieldsA
+			testFieldsTagged
+		}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := json.Marshal(tt.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var props map[string]json.RawMessage
+			if err := json.Unmarshal(b, &props); err != nil {
+				t.Fatal(err)
+			}
+			var want []string
+			for name := range props {
+				want = append(want, name)
+			}
+			sort.Strings(want)
+
+			var got []string
+			for _, f := range jsonFields(reflect.TypeOf(tt.v)) {
+				got = append(got, f.name)
+				if value := props[f.name]; f.quoted && (len(value) == 0 || value[0] != '"') {
+					t.Errorf("%s: quoted, encoded as %s", f.name, props[f.name])
+				}
+			}
+			sort.Strings(got)
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got fields %q, want %q from %s", got, want, b)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package jsonschema
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Draft is a JSON schema specification draft.
+type Draft string
+
+// Supported JSON schema drafts.
+const (
+	Draft07     Draft = "draft-07"
+	Draft201909 Draft = "2019-09"
+	Draft202012 Draft = "2020-12"
+)
+
+// draftURIs are the meta-schema URIs of the supported drafts.
+var draftURIs = map[Draft]string{
+	Draft07:     "http://json-schema.org/draft-07/schema#",
+	Draft201909: "https://json-schema.org/draft/2019-09/schema",
+	Draft202012: "https://json-schema.org/draft/2020-12/schema",
+}
+
+// DocSource is a source of documentation for the Caddy config
+// structure and modules.
+type DocSource interface {
+	LoadDocs(ctx context.Context) (*Docs, error)
+}
+
+// Generator generates JSON schema for the Caddy modules in the
+// current build.
+//
+// A Generator keeps no state between calls to Generate, it is safe
+// to call Generate repeatedly and concurrently.
+type Generator struct {
+	// Docs is the documentation source for the schema.
+	// If nil, the schema is generated without documentation.
+	Docs DocSource
+
+	// Filter reports if the module with the given ID should be
+	// included in the schema. If nil, all modules are included.
+	Filter func(moduleID string) bool
+
+	// Placeholders widens non-string scalar fields to also accept
+	// Caddy placeholders. If nil, placeholders are not accepted.
+	Placeholders *Placeholders
+
+	// Draft is the JSON schema draft of the generated schema.
+	// Defaults to Draft07.
+	Draft Draft
+
+	// ID is the $id of the generated schema. If empty, the schema
+	// has no $id.
+	ID string
+
+	// Closed rejects unknown properties in structs, e.g. typos in
+	// field names. Caddy ignores unknown properties when loading a
+	// config.
+	Closed bool
+
+	// Required derives required properties from json tags without
+	// omitempty, the errors of provisioning and validating zero-valued
+	// modules, and a table of known requirements. Modules panicking
+	// while probed have no derived requirements.
+	//
+	// Modules are probed in a throwaway config that is never started,
+	// it replaces the default Caddy logger. Do not probe in a running
+	// Caddy instance.
+	Required bool
+
+	// Defaults derives the defaults of module properties by provisioning
+	// zero-valued modules and recording the properties set. Modules
+	// failing to provision have no defaults.
+	//
+	// Like Required, it probes modules in a throwaway config.
+	Defaults bool
+
+	// Examples attaches examples to the schema. If nil, the schema
+	// has no examples.
+	Examples *Examples
+
+	// Strict fails the generation if documentation is missing for
+	// any module other than the modules of this package.
+	// It has no effect if Docs is nil.
+	Strict bool
+}
+
+// Report is a summary of the problems encountered during schema
+// generation.
+type Report struct {
+	// DocErrors are the errors encountered retrieving docs, mapped
+	// by module path.
+	DocErrors map[string]error
+
+	// Undocumented lists the modules in the schema without
+	// documentation. The modules of this package are not listed.
+	Undocumented []string
+
+	// UnsupportedLoaders lists the module loader fields of types the
+	// schema cannot be derived for. The fields accept any value.
+	UnsupportedLoaders []string
+
+	// ProbeErrors are the panics and errors other than missing values
+	// of probing modules for Required and Defaults, mapped by module ID.
+	ProbeErrors map[string]error
+}
+
+// Empty reports if there are no problems in the report.
+func (r *Report) Empty() bool {
+	return len(r.DocErrors) == 0 && len(r.Undocumented) == 0 && len(r.UnsupportedLoaders) == 0 &&
+		len(r.ProbeErrors) == 0
+}
+
+// Generate generates the JSON schema for the Caddy JSON config.
+func (g Generator) Generate(ctx context.Context) (*Schema, error) {
+	s, _, err := g.GenerateWithReport(ctx)
+	return s, err
+}
+
+// GenerateWithReport generates the JSON schema for the Caddy JSON config
+// and reports the problems encountered.
+// If Strict is set and docs are missing, the generated schema and report
+// are returned alongside the error.
+func (g Generator) GenerateWithReport(ctx context.Context) (*Schema, *Report, error) {
+	draft := g.Draft
+	if draft == "" {
+		draft = Draft07
+	}
+	if _, ok := draftURIs[draft]; !ok {
+		return nil, nil, fmt.Errorf("unsupported JSON schema draft '%s'", draft)
+	}
+
+	docs := &Docs{}
+	if g.Docs != nil {
+		var err error
+		if docs, err = g.Docs.LoadDocs(ctx); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	gen := &generation{
+		filter:       g.Filter,
+		placeholders: g.Placeholders,
+		docs:         docs,
+		draft:        draft,
+		closed:       g.Closed,
+		required:     g.Required,
+		defaults:     g.Defaults,
+		examples:     g.Examples,
+		inlineKeys:   map[string]string{},
+		typeDefs:     map[string]*Schema{},
+		documented:   map[*Schema]bool{},
+		populating:   map[reflect.Type]string{},
+
+		unsupportedLoaders: map[string]bool{},
+		probeErrors:        map[string]error{},
+		moduleMap:          map[string]Modules{},
+		flatModuleMap:      Modules{},
+	}
+	schema, err := gen.generate(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	schema.setDraft(draft)
+	schema.SchemaURI = draftURIs[draft]
+	schema.ID = g.ID
+
+	report := &Report{DocErrors: docs.Errors}
+	if g.Docs != nil {
+		for mod := range gen.flatModuleMap {
+			if ownModules[mod] {
+				// documented by this package, not by doc sources
+				continue
+			}
+			if doc := docs.Modules[mod]; doc == nil || doc.Result.Structure == nil {
+				report.Undocumented = append(report.Undocumented, mod)
+			}
+		}
+		sort.Strings(report.Undocumented)
+	}
+	for field := range gen.unsupportedLoaders {
+		report.UnsupportedLoaders = append(report.UnsupportedLoaders, field)
+	}
+	sort.Strings(report.UnsupportedLoaders)
+	if len(gen.probeErrors) > 0 {
+		report.ProbeErrors = gen.probeErrors
+	}
+
+	if g.Strict && len(report.Undocumented) > 0 {
+		return schema, report, fmt.Errorf("strict: %d modules without documentation", len(report.Undocumented))
+	}
+	return schema, report, nil
+}
+
+// ownModules are the IDs of the modules provided by this package e.g.
+// the admin API and config adapter modules.
+var ownModules = map[string]bool{
+	string(AdminAPI{}.CaddyModule().ID):   true,
+	"caddy.adapters." + strictAdapterName: true,
+}
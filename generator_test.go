@@ -0,0 +1,49 @@
+package jsonschema
+
+import (
+	"context"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2"
+)
+
+// testDocSource is a DocSource without docs.
+type testDocSource struct{}
+
+func (testDocSource) LoadDocs(context.Context) (*Docs, error) {
+	return &Docs{Modules: map[string]*DocAPIResp{}, Errors: map[string]error{}}, nil
+}
+
+type testUndocumented struct{}
+
+func (testUndocumented) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{ID: "test.undocumented", New: func() caddy.Module { return new(testUndocumented) }}
+}
+
+func init() {
+	caddy.RegisterModule(testUndocumented{})
+}
+
+func TestStrictOwnModules(t *testing.T) {
+	g := Generator{
+		Docs:   testDocSource{},
+		Strict: true,
+		Filter: func(moduleID string) bool { return ownModules[moduleID] },
+	}
+	_, report, err := g.GenerateWithReport(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Undocumented) > 0 {
+		t.Errorf("got undocumented modules %v", report.Undocumented)
+	}
+
+	g.Filter = func(moduleID string) bool { return ownModules[moduleID] || moduleID == "test.undocumented" }
+	_, report, err = g.GenerateWithReport(context.Background())
+	if err == nil {
+		t.Error("got no error for undocumented module")
+	}
+	if len(report.Undocumented) != 1 || report.Undocumented[0] != "test.undocumented" {
+		t.Errorf("got undocumented modules %v, want [test.undocumented]", report.Undocumented)
+	}
+}
@@ -1,15 +1,12 @@
 package jsonschema
 
 import (
+	"encoding"
 	"encoding/json"
 	"fmt"
-	"os"
 	"reflect"
 	"strings"
 	"unicode"
-
-	"github.com/caddyserver/caddy/v2"
-	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
 )
 
 // Interface is a Go type representing a Caddy module structure
@@ -24,19 +21,65 @@ type Interface struct {
 	Type     string
 	Nullable bool
 
+	// predefined schema for types with known JSON representation
+	Custom *Schema
+
 	// array/map type
 	Array bool
 	Map   bool // map key is always string
 	Nest  *Interface
 
 	// Module loaders
-	Loader     []string // list of modules
-	LoaderKey  string   // inline_key
-	LoaderType reflect.Type
+	Loader          []string // list of modules
+	LoaderKey       string   // inline_key
+	LoaderNamespace string
+	LoaderType      reflect.Type
+
+	// closed structs reject unknown properties
+	closed bool
+
+	// provided is set if Custom is provided by the type, see
+	// JSONSchemaProvider
+	provided bool
+
+	// required is set for required struct fields
+	required bool
+
+	// defaultValue is the JSON default of struct fields
+	defaultValue json.RawMessage
+
+	// enum are the known values of struct fields, see enumOverrides
+	enum []string
+
+	// typeName is the Go type name of structs,
+	// "<package path>.<type name>"
+	typeName string
+	goType   reflect.Type
+
+	// inline is set for structs generated in place and never as a
+	// shared definition e.g. modules
+	inline bool
+
+	// shared is set for the definitions of shared Go types
+	shared bool
+
+	// recursiveRef is the reference to the definition of the enclosing
+	// struct of the same type, for recursive types
+	recursiveRef string
+
+	// the generation the Interface belongs to, always set
+	gen *generation
 }
 
 func (f Interface) goPkg() string {
-	typ := reflect.TypeOf(flatModuleMap[f.Module].Type)
+	// shared definitions link to their own type
+	if f.shared {
+		if isPublic(f.Name) {
+			return f.typeName
+		}
+		return ""
+	}
+	typ := reflect.TypeOf(f.gen.flatModuleMap[f.Module].Type)
 	if typ == nil {
 		return ""
 	}
@@ -56,8 +99,17 @@ func (f Interface) goPkg() string {
 
 // toSchema converts the Interface to JSON schema.
 func (f Interface) toSchema() *Schema {
+	if ref := f.typeRef(); ref != nil {
+		f.describe(ref, "object")
+		return ref
+	}
+
 	var s = NewSchema()
-	s.setType(f.Type)
+	if f.Custom != nil {
+		*s = *f.Custom
+	} else {
+		s.setType(f.Type)
+	}
 	s.nullable = f.Nullable
 
 	// if it's a module loader, construct a special case (sub)schema
@@ -71,19 +123,58 @@ func (f Interface) toSchema() *Schema {
 	for _, field := range f.Fields {
 		s.Properties[field.Name] = field.toSchema()
 	}
+	if f.closed {
+		f.gen.closeSchema(s)
+	}
+	if f.gen.required {
+		s.Required = append(s.Required, f.requiredFields()...)
+	}
 
 	// get arrays and maps
 	for cs, outer, nest := s, &f, f.Nest; nest != nil; outer, nest = nest, nest.Nest {
+		if ref := nest.typeRef(); ref != nil {
+			// shared struct, no further nesting
+			if outer.Array {
+				cs.setType("array")
+				cs.ArrayItems = ref
+			}
+			if outer.Map {
+				cs.setType("object")
+				cs.AdditionalProperties = ref
+			}
+			break
+		}
+
 		props := map[string]*Schema{}
 		for _, field := range nest.Fields {
 			props[field.Name] = field.toSchema()
 		}
+		if nest.Custom != nil {
+			// predefined schema, no further nesting
+			if outer.Array {
+				cs.setType("array")
+				cs.ArrayItems = f.withPlaceholder(nest.toSchema())
+			}
+			if outer.Map {
+				cs.setType("object")
+				cs.AdditionalProperties = f.withPlaceholder(nest.toSchema())
+			}
+			break
+		}
+
 		if outer.Array {
 			cs.setType("array")
 			cs.ArrayItems = NewSchema()
 			cs.ArrayItems.setType(nest.Type)
 			cs.ArrayItems.nullable = nest.Nullable
 			cs.ArrayItems.Properties = props
+			if nest.closed {
+				f.gen.closeSchema(cs.ArrayItems)
+			}
+			if f.gen.required {
+				cs.ArrayItems.Required = nest.requiredFields()
+			}
+			cs.ArrayItems = f.withPlaceholder(cs.ArrayItems)
 
 			// nested schema
 			cs = cs.ArrayItems
@@ -92,7 +183,18 @@ func (f Interface) toSchema() *Schema {
 		if outer.Map {
 			cs.setType("object")
 			cs.AdditionalProperties = NewSchema()
+			if isScalar(getType(nest.Type)) {
+				// typed like scalar array items
+				cs.AdditionalProperties.setType(nest.Type)
+			}
 			cs.AdditionalProperties.Properties = props
+			if nest.closed {
+				f.gen.closeSchema(cs.AdditionalProperties)
+			}
+			if f.gen.required {
+				cs.AdditionalProperties.Required = nest.requiredFields()
+			}
+			cs.AdditionalProperties = f.withPlaceholder(cs.AdditionalProperties)
 
 			// nested schema
 			cs = cs.AdditionalProperties
@@ -100,10 +202,36 @@ func (f Interface) toSchema() *Schema {
 	}
 
 	// now we're certain of the type
-	s.description = description(f.Name, s.Type, f.Module)
-	s.markdownDescription = markdownDescription(f.Name, s.Type, f.Module)
+	typ := s.Type
+	if f.Custom != nil {
+		typ = f.Type
+	}
+
+	setEnum(s, f.enum)
+	s = f.withPlaceholder(s)
+	f.describe(s, typ)
+	return s
+}
+
+// describe sets the default and descriptions of the schema s of type typ.
+func (f Interface) describe(s *Schema, typ string) {
+	s.Default = f.defaultValue
+	s.description = description(f.Name, typ, f.Module)
+	s.markdownDescription = markdownDescription(f.Name, typ, f.Module)
 	s.goPkg = f.goPkg()
 
+	// keep the descriptions of types providing their own schema
+	if f.provided && (f.Custom.Description != "" || f.Custom.MarkdownDescription != "") {
+		s.description = f.Custom.Description
+		s.markdownDescription = f.Custom.MarkdownDescription
+		if s.description == "" {
+			s.description = s.markdownDescription
+		}
+		if s.markdownDescription == "" {
+			s.markdownDescription = s.description
+		}
+	}
+
 	// set the description in case JSON api docs not available
 	// e.g. third party modules (for now)
 	s.Description = s.description + "\n" + godocLink(s.goPkg)
@@ -112,6 +240,134 @@ func (f Interface) toSchema() *Schema {
 		s.Description = s.description
 		s.MarkdownDescription = s.markdownDescription
 	}
+}
+
+// typeDefPrefix prefixes the names of definitions of shared Go types.
+const typeDefPrefix = "go:"
+
+// typeRef returns a reference to the shared definition of the named
+// struct f, generating the definition at first use. Nil is returned if
+// f is not shared e.g. modules, module loaders and anonymous structs.
+//
+// Shared definitions are module independent, unless placeholders are
+// configured as the placeholders allowed depend on the module.
+func (f Interface) typeRef() *Schema {
+	if f.recursiveRef != "" {
+		ref := NewSchema()
+		ref.Ref = f.recursiveRef
+		return ref
+	}
+	if f.inline || f.shared || f.Type != "object" || f.Custom != nil ||
+		len(f.Loader) > 0 || len(f.Fields) == 0 ||
+		f.typeName == "" || strings.HasSuffix(f.typeName, ".") {
+		return nil
+	}
+
+	name := f.defName()
+	if _, ok := f.gen.typeDefs[name]; !ok {
+		f.gen.typeDefs[name] = nil // reserve the name
+
+		// populate the type afresh, the fields of f may reference
+		// the definitions enclosing f in recursive types.
+		def := Interface{
+			Module: f.Module,
+			Name:   f.typeName[strings.LastIndex(f.typeName, ".")+1:],
+			shared: true,
+			gen:    f.gen,
+		}
+		def.populate(reflect.Zero(f.goType).Interface())
+		if f.gen.placeholders == nil {
+			def = def.detached(f.Module)
+		}
+		f.gen.typeDefs[name] = def.toSchema()
+	}
+
+	ref := NewSchema()
+	ref.Ref = "#/definitions/" + escapePointer(name)
+	ref.nullable = f.Nullable
+	return ref
+}
+
+// defName returns the name of the shared definition of the struct f.
+func (f Interface) defName() string {
+	if f.gen.placeholders != nil {
+		return typeDefPrefix + f.typeName + "@" + f.Module
+	}
+	return typeDefPrefix + f.typeName
+}
+
+// selfRef returns the reference to the definition of the struct f.
+func (f Interface) selfRef() string {
+	switch {
+	case f.inline && f.Module == "":
+		return "#" // root
+	case f.inline:
+		return "#/definitions/" + f.Module
+	}
+	return "#/definitions/" + escapePointer(f.defName())
+}
+
+// typeDefName returns the name of the shared Go type definition
+// referenced by ref, or an empty string if ref is not a reference to a
+// shared definition.
+func typeDefName(ref string) string {
+	name := unescapePointer(strings.TrimPrefix(ref, "#/definitions/"))
+	if !strings.HasPrefix(name, typeDefPrefix) {
+		return ""
+	}
+	return name
+}
+
+// detached returns a copy of f without the module for f and its nested
+// fields in the module, for definitions shared between modules.
+// Module loaders keep their namespaces.
+func (f Interface) detached(module string) Interface {
+	if f.Module == module {
+		f.Module = ""
+	}
+	fields := make([]Interface, len(f.Fields))
+	for i, field := range f.Fields {
+		fields[i] = field.detached(module)
+	}
+	f.Fields = fields
+	if f.Nest != nil {
+		nest := f.Nest.detached(module)
+		f.Nest = &nest
+	}
+	return f
+}
+
+// idPattern matches the idKey property name.
+const idPattern = "^" + idKey + "$"
+
+// closeSchema rejects unknown properties in the object schema s. The
+// idKey property identifying objects in the admin API is allowed.
+// Drafts after draft-07 reject with unevaluatedProperties, which also
+// sees the properties of in-place subschemas e.g. module loaders.
+func (g *generation) closeSchema(s *Schema) {
+	id := NewSchema()
+	for _, typ := range []string{"string", "number"} {
+		sub := NewSchema()
+		sub.Type = typ
+		id.AnyOf = append(id.AnyOf, sub)
+	}
+	id.Description = "identifier of the object in the admin API"
+	id.MarkdownDescription = id.Description
+	s.PatternProperties = map[string]*Schema{idPattern: id}
+
+	if g.draft == Draft07 {
+		s.AdditionalProperties = falseSchema()
+	} else {
+		s.UnevaluatedProperties = falseSchema()
+	}
+}
+
+// withPlaceholder widens the scalar schema s of f, or of its array
+// items and map values, to accept placeholders where allowed.
+func (f Interface) withPlaceholder(s *Schema) *Schema {
+	if isScalar(s.Type) && f.gen.placeholders.allowed(f.Module, f.Name) {
+		return withPlaceholder(s)
+	}
 	return s
 }
 
@@ -127,6 +383,28 @@ func (f *Interface) populate(s interface{}) {
 
 	v := reflect.ValueOf(s)
 
+	// types providing their own schema
+	if custom := providedSchema(v.Type()); custom != nil {
+		f.Type = custom.Type
+		f.Custom = custom
+		f.provided = true
+		return
+	}
+
+	// special-cased types
+	if known, ok := knownTypes[v.Type()]; ok {
+		f.Type = known.name
+		f.Custom = known.schema()
+		return
+	}
+
+	// types decoding JSON themselves
+	if name, custom := customRepresentation(v.Type()); custom != nil {
+		f.Type = name
+		f.Custom = custom
+		return
+	}
+
 	elemVal := func() interface{} { return reflect.Zero(v.Type().Elem()).Interface() }
 
 	switch v.Kind() {
@@ -142,6 +420,7 @@ func (f *Interface) populate(s interface{}) {
 		f.Nest = &Interface{
 			Module: f.Module,
 			Name:   f.Name + ".nest",
+			gen:    f.gen,
 		}
 		f.Nest.populate(elemVal())
 
@@ -150,6 +429,7 @@ func (f *Interface) populate(s interface{}) {
 		f.Nest = &Interface{
 			Module: f.Module,
 			Name:   f.Name + ".nest",
+			gen:    f.gen,
 		}
 		f.Nest.populate(elemVal())
 
@@ -167,86 +447,142 @@ func (f *Interface) populateStruct(t reflect.Type) {
 		f.Type = t.Kind().String()
 		return
 	}
+	f.typeName = t.PkgPath() + "." + t.Name()
+	f.goType = t
+
+	// recursive types reference the enclosing struct
+	if ref, ok := f.gen.populating[t]; ok {
+		f.Type = getType("object")
+		f.recursiveRef = ref
+		return
+	}
+	f.gen.populating[t] = f.selfRef()
+	defer delete(f.gen.populating, t)
 
 	// rootLoaders are special type of module loaders where
 	// module loading happens on the struct directly but not the
-	// struct fields. Currently only applies to caddyhttp.MatchNot
-	rootLoader := t == reflect.TypeOf(caddyhttp.MatchNot{})
-
-	publicFields := []reflect.StructField{}
-	for _, ff := range allFields(t) {
-		if isPublic(ff.Name) {
-			publicFields = append(publicFields, ff)
-		}
-
-		jsonTag, ok := ff.Tag.Lookup("json")
-
-		if _, ok := ff.Tag.Lookup("caddy"); !ok {
-			rootLoader = false // discard if missing struct tag
+	// struct fields e.g. caddyhttp.MatchNot
+	if isRootLoader(t) {
+		for _, ff := range allFields(t) {
+			if caddyTag, ok := ff.Tag.Lookup("caddy"); ok {
+				// delegate loading to parent struct
+				f.setLoader(caddyTag, ff.Type)
+				return
+			}
 		}
+	}
 
-		if (!ok || jsonTag == "-") && !rootLoader {
+	// fields are decoded by their Go names if untagged,
+	// unless the struct has custom unmarshalling.
+	custom := customUnmarshaler(t)
+	for _, ff := range jsonFields(t) {
+		if custom && !ff.tagged {
 			continue
 		}
 
 		field := Interface{
-			Module: f.Module,
-			Name:   strings.TrimSuffix(jsonTag, ",omitempty"),
+			Module:   f.Module,
+			Name:     ff.name,
+			required: f.gen.required && !ff.omitEmpty,
+			enum:     enumOverrides[f.typeName+"/"+ff.name],
+			gen:      f.gen,
 		}
 
 		caddyTag, ok := ff.Tag.Lookup("caddy")
 		if !ok {
 			// regular fields
 			field.populate(reflect.Zero(ff.Type).Interface())
+			if ff.quoted {
+				field.Type = "string"
+				field.Custom = quotedSchema(ff.Type)
+			}
 			f.Fields = append(f.Fields, field)
 			continue
 		}
 
 		// module loader fields
-		split := strings.Fields(caddyTag)
-		namespace := split[0] // 1 is inline_key
-		namespace = strings.TrimPrefix(namespace, "namespace=")
+		field.Module = field.setLoader(caddyTag, ff.Type) // use namespace as module
+		f.Fields = append(f.Fields, field)
+	}
 
-		if len(split) > 1 {
-			field.LoaderKey = strings.TrimPrefix(split[1], "inline_key=")
+	var publicFields []reflect.StructField
+	for _, ff := range allFields(t) {
+		if isPublic(ff.Name) {
+			publicFields = append(publicFields, ff)
 		}
+	}
 
-		field.Module = namespace // use namespace as module
-		field.LoaderType = ff.Type
+	// structs maps to object in JSON
+	f.Type = getType("object")
 
-		for key := range moduleMap[namespace] {
-			modulePath := key
-			if namespace != "" {
-				modulePath = namespace + "." + key
-			}
-			field.Loader = append(field.Loader, modulePath)
+	// for structs with custom unmarshalling and no json tagged fields.
+	// if there's only one public field, assume the type of the field,
+	// otherwise the representation is unknown.
+	if custom && len(f.Fields) == 0 {
+		f.Type = "any"
+		if len(publicFields) == 1 {
+			tmp := Interface{gen: f.gen}
+			tmp.populate(reflect.Zero(publicFields[0].Type).Interface())
+			f.Type, f.Custom = tmp.Type, tmp.Custom
 		}
+	}
 
-		if rootLoader {
-			// delegate loading to parent struct
-			// discard all fields
-			f.Fields = nil
-			f.Loader = field.Loader
-			f.LoaderType = field.LoaderType
-			f.LoaderKey = field.LoaderKey
-			return
-		}
+	f.closed = f.gen.closed && f.Type == "object" && !custom
+}
 
-		f.Fields = append(f.Fields, field)
+// setLoader sets f to load the modules of the namespace in the caddy
+// struct tag, from a field of type t. The namespace is returned.
+func (f *Interface) setLoader(caddyTag string, t reflect.Type) string {
+	split := strings.Fields(caddyTag)
+	namespace := split[0] // 1 is inline_key
+	namespace = strings.TrimPrefix(namespace, "namespace=")
+
+	if len(split) > 1 {
+		f.LoaderKey = strings.TrimPrefix(split[1], "inline_key=")
 	}
 
-	// structs maps to object in JSON
-	f.Type = getType("object")
+	f.LoaderNamespace = namespace
+	f.LoaderType = t
+	if f.LoaderKey != "" {
+		f.gen.inlineKeys[namespace] = f.LoaderKey
+	}
 
-	// for structs with custom unmarshalling and no json tagged fields.
-	// if there's only one public field, assume the type of the field.
-	// TODO: decide if this is necessary or simply leave as any.
-	if len(f.Fields) == 0 && len(publicFields) == 1 {
-		tmp := Interface{}
-		tmp.populate(reflect.Zero(publicFields[0].Type).Interface())
-		f.Type = tmp.Type
+	for key := range f.gen.moduleMap[namespace] {
+		modulePath := key
+		if namespace != "" {
+			modulePath = namespace + "." + key
+		}
+		f.Loader = append(f.Loader, modulePath)
+	}
+	return namespace
+}
+
+// isRootLoader reports if the struct t is a root loader, decoded from the
+// modules of its single module loader field. Root loaders unmarshal JSON
+// themselves and have no other JSON properties.
+func isRootLoader(t reflect.Type) bool {
+	if !reflect.PtrTo(t).Implements(reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()) {
+		return false
 	}
+	loaders := 0
+	for _, ff := range allFields(t) {
+		if _, ok := ff.Tag.Lookup("caddy"); ok {
+			loaders++
+			continue
+		}
+		if jsonTag, ok := ff.Tag.Lookup("json"); ok && jsonTag != "-" {
+			return false
+		}
+	}
+	return loaders == 1
+}
 
+// customUnmarshaler reports if t implements custom JSON or text
+// unmarshalling.
+func customUnmarshaler(t reflect.Type) bool {
+	p := reflect.PtrTo(t)
+	return p.Implements(reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()) ||
+		p.Implements(reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem())
 }
 
 func isPublic(fieldName string) bool {
@@ -360,36 +696,69 @@ func (m *moduleLoaderSchemaBuilder) buildWithInlineKey() {
 	m.s.Required = []string{m.f.LoaderKey}
 }
 
+// apply sets the schema of the module loader field to parent. Fields of
+// unsupported types are left unconstrained and reported.
 func (m *moduleLoaderSchemaBuilder) apply(parent *Schema) {
-	// use an hack to determine the module loader type
-	switch reflect.Zero(m.f.LoaderType).Interface().(type) {
+	s := m.schema(m.f.LoaderType)
+	if s == nil {
+		m.f.gen.unsupportedLoaders[fmt.Sprintf("%s (namespace %s): %v", m.f.Name, m.f.LoaderNamespace, m.f.LoaderType)] = true
+		return
+	}
+	*parent = *s
+}
 
-	case json.RawMessage:
+// schema returns the schema for the module loader field of type t, or
+// nil if t is not supported. Types are analysed the way Caddy loads
+// modules, any combination of slices and maps of json.RawMessage is
+// supported e.g. map[string][]json.RawMessage.
+func (m *moduleLoaderSchemaBuilder) schema(t reflect.Type) *Schema {
+	switch {
+	case isRawMessage(t):
 		// module
-		*parent = *m.s
-		parent.setType("object")
+		s := *m.s
+		s.setType("object")
+		return &s
+
+	case t.Kind() == reflect.Ptr:
+		return m.schema(t.Elem())
 
-	case []json.RawMessage:
+	case t.Kind() == reflect.Slice:
 		// []module
-		parent.setType("array")
-		parent.ArrayItems = m.s
+		items := m.schema(t.Elem())
+		if items == nil {
+			return nil
+		}
+		s := NewSchema()
+		s.setType("array")
+		s.ArrayItems = items
+		return s
 
-	case map[string]json.RawMessage, caddy.ModuleMap:
-		// map[string]module
+	case t.Kind() == reflect.Map && t.Key().Kind() == reflect.String:
 		if m.f.LoaderKey == "" {
-			*parent = *m.s
-		} else {
-			parent.AdditionalProperties = m.s
+			// map[string]module keyed by module name e.g. caddy.ModuleMap
+			if !isRawMessage(t.Elem()) {
+				return nil
+			}
+			s := *m.s
+			s.setType("object")
+			return &s
 		}
-		parent.setType("object")
 
-	case []map[string]json.RawMessage, []caddy.ModuleMap, caddyhttp.RawMatcherSets:
-		// []map[string]module
-		parent.setType("array")
-		parent.ArrayItems = m.s
-
-	default:
-		fmt.Fprintf(os.Stderr, "cannot deduce type for field '%s' in module '%s' for module loader: '%v'", m.f.Name, m.f.Module, m.f.LoaderType)
+		// map[string]module with module names inline
+		values := m.schema(t.Elem())
+		if values == nil {
+			return nil
+		}
+		s := NewSchema()
+		s.setType("object")
+		s.AdditionalProperties = values
+		return s
 	}
+	return nil
+}
 
+// isRawMessage reports if t is json.RawMessage or a type with the same
+// underlying type.
+func isRawMessage(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
 }
@@ -0,0 +1,444 @@
+package jsonschema
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2"
+)
+
+// generateTest generates the schema of the modules with the prefix.
+func generateTest(t *testing.T, g Generator, prefix string) *Schema {
+	t.Helper()
+	g.Filter = func(moduleID string) bool { return strings.HasPrefix(moduleID, prefix) }
+	s, err := g.Generate(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// placeholder types
+
+type testPlaceholders struct {
+	Port     int            `json:"port,omitempty"`
+	Statuses []int          `json:"statuses,omitempty"`
+	Weights  map[string]int `json:"weights,omitempty"`
+	Names    []string       `json:"names,omitempty"`
+}
+
+func (testPlaceholders) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{ID: "test.placeholders", New: func() caddy.Module { return new(testPlaceholders) }}
+}
+
+func init() {
+	caddy.RegisterModule(testPlaceholders{})
+}
+
+func TestPlaceholderItems(t *testing.T) {
+	s := generateTest(t, Generator{Placeholders: &Placeholders{}}, "test.placeholders")
+	props := s.Definitions["test.placeholders"].Properties
+
+	tests := []struct {
+		name   string
+		schema *Schema
+		widen  bool
+	}{
+		{"scalar", props["port"], true},
+		{"array items", props["statuses"].ArrayItems, true},
+		{"map values", props["weights"].AdditionalProperties, true},
+		{"string items", props["names"].ArrayItems, false},
+	}
+	for _, tt := range tests {
+		if widened := len(tt.schema.AnyOf) == 2 && tt.schema.AnyOf[1].Pattern == placeholderPattern; widened != tt.widen {
+			t.Errorf("%s: got %+v, want placeholder %v", tt.name, tt.schema, tt.widen)
+		}
+	}
+
+}
+
+// schema provider types
+
+type testProvided struct{}
+
+func (testProvided) JSONSchema() *Schema {
+	s := NewSchema()
+	s.Type = "string"
+	s.Description = "provided description"
+	return s
+}
+
+type testProvidedMarkdown struct{}
+
+func (testProvidedMarkdown) JSONSchema() *Schema {
+	s := NewSchema()
+	s.Type = "string"
+	s.MarkdownDescription = "provided `markdown` description"
+	return s
+}
+
+type testProvider struct {
+	Provided testProvided         `json:"provided,omitempty"`
+	Markdown testProvidedMarkdown `json:"markdown,omitempty"`
+	Plain    string               `json:"plain,omitempty"`
+}
+
+func (testProvider) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{ID: "test.provider", New: func() caddy.Module { return new(testProvider) }}
+}
+
+func init() {
+	caddy.RegisterModule(testProvider{})
+}
+
+func TestProvidedSchemaDescription(t *testing.T) {
+	s := generateTest(t, Generator{}, "test.provider")
+	props := s.Definitions["test.provider"].Properties
+
+	tests := []struct {
+		property    string
+		description string
+		markdown    string
+	}{
+		{"provided", "provided description", "provided description"},
+		{"markdown", "provided `markdown` description", "provided `markdown` description"},
+		{"plain", "plain: string", "plain: `string`"},
+	}
+	for _, tt := range tests {
+		p := props[tt.property]
+		if !strings.HasPrefix(p.Description, tt.description) {
+			t.Errorf("%s: got description %q, want %q", tt.property, p.Description, tt.description)
+		}
+		if !strings.HasPrefix(p.MarkdownDescription, tt.markdown) {
+			t.Errorf("%s: got markdown description %q, want %q", tt.property, p.MarkdownDescription, tt.markdown)
+		}
+	}
+}
+
+// recursive module types
+
+type testSelf struct {
+	Value  int                  `json:"value,omitempty"`
+	Inner  []*testSelf          `json:"inner,omitempty"`
+	Next   *testSelf            `json:"next,omitempty"`
+	ByName map[string]*testSelf `json:"by_name,omitempty"`
+}
+
+func (testSelf) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{ID: "test.recursive.self", New: func() caddy.Module { return new(testSelf) }}
+}
+
+type TestNode struct {
+	Name     string              `json:"name,omitempty"`
+	Children []TestNode          `json:"children,omitempty"`
+	ByName   map[string]TestNode `json:"by_name,omitempty"`
+}
+
+type testTree struct {
+	Root TestNode `json:"root,omitempty"`
+}
+
+func (testTree) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{ID: "test.recursive.tree", New: func() caddy.Module { return new(testTree) }}
+}
+
+// TestMutualA and TestMutualB reference each other, TestMutualA is
+// also a module.
+type TestMutualA struct {
+	B TestMutualB `json:"b,omitempty"`
+}
+
+type TestMutualB struct {
+	A *TestMutualA `json:"a,omitempty"`
+}
+
+func (TestMutualA) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{ID: "test.recursive.mutual", New: func() caddy.Module { return new(TestMutualA) }}
+}
+
+type testUsesB struct {
+	B TestMutualB `json:"b,omitempty"`
+}
+
+func (testUsesB) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{ID: "test.recursive.usesb", New: func() caddy.Module { return new(testUsesB) }}
+}
+
+func init() {
+	caddy.RegisterModule(testSelf{})
+	caddy.RegisterModule(testTree{})
+	caddy.RegisterModule(TestMutualA{})
+	caddy.RegisterModule(testUsesB{})
+}
+
+// typeDefRef returns the reference to the shared definition of v.
+func typeDefRef(v interface{}) string {
+	return "#/definitions/" + escapePointer(typeDefPrefix+typeName(v))
+}
+
+// typeName returns the Go type name of v, "<package path>.<type name>".
+func typeName(v interface{}) string {
+	t := reflect.TypeOf(v)
+	return t.PkgPath() + "." + t.Name()
+}
+
+func TestRecursiveTypes(t *testing.T) {
+	s := generateTest(t, Generator{}, "test.recursive.")
+
+	self := s.Definitions["test.recursive.self"]
+	tree := s.Definitions[typeDefPrefix+typeName(TestNode{})]
+	mutualA := s.Definitions[typeDefPrefix+typeName(TestMutualA{})]
+	mutualB := s.Definitions[typeDefPrefix+typeName(TestMutualB{})]
+	if self == nil || tree == nil || mutualA == nil || mutualB == nil {
+		t.Fatalf("missing definitions: %v", s.Definitions)
+	}
+
+	tests := []struct {
+		name string
+		ref  *Schema
+		want string
+	}{
+		{"self pointer", self.Properties["next"], "#/definitions/test.recursive.self"},
+		{"self slice", self.Properties["inner"].ArrayItems, "#/definitions/test.recursive.self"},
+		{"self map", self.Properties["by_name"].AdditionalProperties, "#/definitions/test.recursive.self"},
+		{"shared slice", tree.Properties["children"].ArrayItems, typeDefRef(TestNode{})},
+		{"shared map", tree.Properties["by_name"].AdditionalProperties, typeDefRef(TestNode{})},
+		{"mutual a", mutualB.Properties["a"], typeDefRef(TestMutualA{})},
+		{"mutual b", mutualA.Properties["b"], typeDefRef(TestMutualB{})},
+		{"module b", s.Definitions["test.recursive.mutual"].Properties["b"], typeDefRef(TestMutualB{})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.ref == nil || tt.ref.Ref != tt.want {
+				t.Errorf("got %+v, want $ref %s", tt.ref, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecursiveTypesDeterministic(t *testing.T) {
+	want, err := json.Marshal(generateTest(t, Generator{}, "test.recursive."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		got, err := json.Marshal(generateTest(t, Generator{}, "test.recursive."))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(want) {
+			t.Fatalf("run %d: schema differs between generations", i)
+		}
+	}
+}
+
+func TestRecursiveTypesValidate(t *testing.T) {
+	s := generateTest(t, Generator{Closed: true}, "test.recursive.")
+
+	root := NewSchema()
+	root.Ref = "#/definitions/test.recursive.self"
+	root.Definitions = s.Definitions
+
+	tests := []struct {
+		config string
+		errors int
+	}{
+		{`{"inner":[{"value":1,"inner":[{"value":2}]}],"next":{"by_name":{"a":{"value":3}}}}`, 0},
+		{`{"inner":[{"value":1,"inner":[{"valu":2}]}]}`, 1},
+		{`{"next":{"next":{"value":"1"}}}`, 1},
+	}
+	for _, tt := range tests {
+		errs, err := root.Validate([]byte(tt.config))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(errs) != tt.errors {
+			t.Errorf("%s: got errors %v, want %d", tt.config, errs, tt.errors)
+		}
+	}
+}
+
+// root loader types
+
+type testMapLoader struct {
+	MatchersRaw caddy.ModuleMap `json:"-" caddy:"namespace=http.matchers"`
+	matchers    []interface{}
+}
+
+func (*testMapLoader) UnmarshalJSON([]byte) error { return nil }
+
+type testSliceLoader struct {
+	Sets        []interface{}     `json:"-"`
+	MatchersRaw []caddy.ModuleMap `json:"-" caddy:"namespace=http.matchers"`
+}
+
+func (*testSliceLoader) UnmarshalJSON([]byte) error { return nil }
+
+type testInlineLoader struct {
+	HandlerRaw json.RawMessage `caddy:"namespace=http.handlers inline_key=handler"`
+}
+
+func (*testInlineLoader) UnmarshalJSON([]byte) error { return nil }
+
+type testTaggedSibling struct {
+	Name       string          `json:"name,omitempty"`
+	HandlerRaw json.RawMessage `json:"-" caddy:"namespace=http.handlers inline_key=handler"`
+}
+
+func (*testTaggedSibling) UnmarshalJSON([]byte) error { return nil }
+
+type testNoUnmarshaler struct {
+	MatchersRaw caddy.ModuleMap `json:"-" caddy:"namespace=http.matchers"`
+}
+
+type testTwoLoaders struct {
+	MatchersRaw caddy.ModuleMap `json:"-" caddy:"namespace=http.matchers"`
+	HandlerRaw  json.RawMessage `json:"-" caddy:"namespace=http.handlers inline_key=handler"`
+}
+
+func (*testTwoLoaders) UnmarshalJSON([]byte) error { return nil }
+
+func TestIsRootLoader(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+		want bool
+	}{
+		{"map loader", testMapLoader{}, true},
+		{"slice loader", testSliceLoader{}, true},
+		{"inline key loader", testInlineLoader{}, true},
+		{"json tagged sibling", testTaggedSibling{}, false},
+		{"no unmarshaler", testNoUnmarshaler{}, false},
+		{"two loaders", testTwoLoaders{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRootLoader(reflect.TypeOf(tt.v)); got != tt.want {
+				t.Errorf("isRootLoader(%T) = %v, want %v", tt.v, got, tt.want)
+			}
+		})
+	}
+}
+
+func (testMapLoader) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{ID: "test.rootloader.map", New: func() caddy.Module { return new(testMapLoader) }}
+}
+
+func (testSliceLoader) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{ID: "test.rootloader.slice", New: func() caddy.Module { return new(testSliceLoader) }}
+}
+
+func (testInlineLoader) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{ID: "test.rootloader.inline", New: func() caddy.Module { return new(testInlineLoader) }}
+}
+
+func (testTaggedSibling) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{ID: "test.rootloader.sibling", New: func() caddy.Module { return new(testTaggedSibling) }}
+}
+
+func init() {
+	caddy.RegisterModule(testMapLoader{})
+	caddy.RegisterModule(testSliceLoader{})
+	caddy.RegisterModule(testInlineLoader{})
+	caddy.RegisterModule(testTaggedSibling{})
+}
+
+func TestRootLoaderSchema(t *testing.T) {
+	s := generateTest(t, Generator{}, "")
+	defs := s.Definitions
+
+	// map loader, properties keyed by matcher name
+	if m := defs["test.rootloader.map"]; m.Type != "object" || m.Properties["path"] == nil ||
+		m.Properties["path"].Ref != "#/definitions/http.matchers.path" {
+		t.Errorf("map loader: got %+v", m)
+	}
+
+	// slice loader, items keyed by matcher name
+	if m := defs["test.rootloader.slice"]; m.Type != "array" || m.ArrayItems == nil ||
+		m.ArrayItems.Properties["path"] == nil {
+		t.Errorf("slice loader: got %+v", m)
+	}
+
+	// inline key loader, module selected by the handler key
+	if m := defs["test.rootloader.inline"]; m.Type != "object" || len(m.AllOf) == 0 ||
+		len(m.Required) != 1 || m.Required[0] != "handler" {
+		t.Errorf("inline key loader: got %+v", m)
+	}
+
+	// not a root loader, the json tagged property is kept
+	if m := defs["test.rootloader.sibling"]; m.Properties["name"] == nil || len(m.AllOf) > 0 {
+		t.Errorf("json tagged sibling: got %+v", m)
+	}
+}
+
+// module loader types
+
+type testRawHandler json.RawMessage
+
+type testLoaders struct {
+	Map         map[string][]json.RawMessage `json:"map,omitempty" caddy:"namespace=http.handlers inline_key=handler"`
+	Nested      [][]json.RawMessage          `json:"nested,omitempty" caddy:"namespace=http.handlers inline_key=handler"`
+	Named       testRawHandler               `json:"named,omitempty" caddy:"namespace=http.handlers inline_key=handler"`
+	Unsupported map[string]int               `json:"unsupported,omitempty" caddy:"namespace=http.handlers inline_key=handler"`
+}
+
+func (testLoaders) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{ID: "test.loaders.fields", New: func() caddy.Module { return new(testLoaders) }}
+}
+
+type testUnsupportedRootLoader struct {
+	MatchersRaw []int `json:"-" caddy:"namespace=http.matchers"`
+}
+
+func (*testUnsupportedRootLoader) UnmarshalJSON([]byte) error { return nil }
+
+func (testUnsupportedRootLoader) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{ID: "test.loaders.root", New: func() caddy.Module { return new(testUnsupportedRootLoader) }}
+}
+
+func init() {
+	caddy.RegisterModule(testLoaders{})
+	caddy.RegisterModule(testUnsupportedRootLoader{})
+}
+
+func TestLoaderSchema(t *testing.T) {
+	g := Generator{Filter: func(moduleID string) bool {
+		return strings.HasPrefix(moduleID, "test.loaders.") || strings.HasPrefix(moduleID, "http.")
+	}}
+	s, report, err := g.GenerateWithReport(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	props := s.Definitions["test.loaders.fields"].Properties
+
+	// isModule reports if m selects modules by the handler key
+	isModule := func(m *Schema) bool {
+		return m != nil && m.Type == "object" && len(m.AllOf) > 0 &&
+			len(m.Required) == 1 && m.Required[0] == "handler"
+	}
+	if m := props["map"]; m.Type != "object" || m.AdditionalProperties == nil ||
+		m.AdditionalProperties.Type != "array" || !isModule(m.AdditionalProperties.ArrayItems) {
+		t.Errorf("map of module lists: got %+v", m)
+	}
+	if m := props["nested"]; m.Type != "array" || m.ArrayItems == nil ||
+		m.ArrayItems.Type != "array" || !isModule(m.ArrayItems.ArrayItems) {
+		t.Errorf("nested module lists: got %+v", m)
+	}
+	if m := props["named"]; !isModule(m) {
+		t.Errorf("named raw message: got %+v", m)
+	}
+	if m := props["unsupported"]; m.Type != "" || len(m.AllOf) > 0 {
+		t.Errorf("unsupported: got %+v", m)
+	}
+
+	want := []string{
+		"root (namespace http.matchers): []int",
+		"unsupported (namespace http.handlers): map[string]int",
+	}
+	if !reflect.DeepEqual(report.UnsupportedLoaders, want) {
+		t.Errorf("got unsupported loaders %q, want %q", report.UnsupportedLoaders, want)
+	}
+}
@@ -0,0 +1,69 @@
+package jsonschema
+
+import (
+	"path"
+)
+
+// placeholderPattern matches a value that is a single Caddy placeholder
+// e.g. {env.PORT}, {http.request.uri.query.limit}.
+const placeholderPattern = `^\{[^{}\s]+\}$`
+
+// Placeholders configures where Caddy placeholders are accepted in
+// place of non-string scalar values i.e. integers, numbers and booleans.
+//
+// Allow and Deny entries are matched with path.Match against the module ID
+// and against "<module ID>/<field>". Fields of the root config have an
+// empty module ID.
+type Placeholders struct {
+	// Allow is the list of modules or fields that accept placeholders.
+	// If empty, all modules and fields are allowed.
+	Allow []string
+
+	// Deny is the list of modules or fields that do not accept
+	// placeholders. It takes precedence over Allow.
+	Deny []string
+}
+
+// allowed reports if the field in module accepts placeholders.
+func (p *Placeholders) allowed(module, field string) bool {
+	if p == nil {
+		return false
+	}
+	if matchAny(p.Deny, module, field) {
+		return false
+	}
+	return len(p.Allow) == 0 || matchAny(p.Allow, module, field)
+}
+
+func matchAny(patterns []string, module, field string) bool {
+	for _, pattern := range patterns {
+		for _, name := range []string{module, module + "/" + field} {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isScalar reports if the JSON schema type is a non-string scalar.
+func isScalar(typ string) bool {
+	switch typ {
+	case "integer", "number", "boolean":
+		return true
+	}
+	return false
+}
+
+// withPlaceholder returns a schema that accepts either s or a
+// placeholder string.
+func withPlaceholder(s *Schema) *Schema {
+	placeholder := NewSchema()
+	placeholder.setType("string")
+	placeholder.Pattern = placeholderPattern
+	placeholder.Description = "Caddy placeholder e.g. {env.PORT}"
+
+	wrapped := NewSchema()
+	wrapped.AnyOf = []*Schema{s, placeholder}
+	return wrapped
+}
@@ -0,0 +1,267 @@
+package jsonschema
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/certmagic"
+)
+
+// caddyPkg is the import path of the Caddy module.
+const caddyPkg = "github.com/caddyserver/caddy/v2"
+
+// requiredOverrides overrides the derived required properties of Go
+// types, mapped by "<package path>.<type name>", for requirements that
+// cannot be derived from the code. A property is required if true and
+// never required if false.
+var requiredOverrides = map[string]map[string]bool{
+	caddyPkg + "/modules/caddyhttp/reverseproxy.Handler": {"upstreams": true},
+}
+
+// missingPattern matches errors about missing values e.g.
+// "hash is required".
+var missingPattern = regexp.MustCompile(`(?i)\b(required|missing|must specify|must be specified|not specified|cannot be empty)\b`)
+
+// probeAppID is the ID of probeApp.
+const probeAppID = "json_schema_probe"
+
+func init() {
+	caddy.RegisterModule(probeApp{})
+}
+
+// probeApp is an app loaded in a throwaway config, to probe modules in
+// the context of a config. Modules need the config e.g. for storage.
+// It is not part of the schema.
+type probeApp struct{}
+
+// probeRun is the function the probeApp provisioning runs.
+var probeRun struct {
+	sync.Mutex
+	fn func(caddy.Context)
+}
+
+// CaddyModule returns the Caddy module information.
+func (probeApp) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  probeAppID,
+		New: func() caddy.Module { return new(probeApp) },
+	}
+}
+
+// Provision runs the probe function with the context of the config.
+func (probeApp) Provision(ctx caddy.Context) error {
+	if probeRun.fn != nil {
+		probeRun.fn(ctx)
+	}
+	return nil
+}
+
+// Start implements caddy.App.
+func (probeApp) Start() error { return nil }
+
+// Stop implements caddy.App.
+func (probeApp) Stop() error { return nil }
+
+// withProbeContext calls fn with the context of a throwaway config that
+// is validated but never started. The config stores in a temporary
+// directory and is cleaned up with its modules before return.
+func withProbeContext(fn func(caddy.Context)) error {
+	dir, err := ioutil.TempDir("", "caddy-json-schema-probe")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	probeRun.Lock()
+	defer probeRun.Unlock()
+	probeRun.fn = fn
+	defer func() { probeRun.fn = nil }()
+
+	// configs without storage use the default storage and make it the
+	// default certmagic storage, restore both
+	defaultStorage, certmagicStorage := caddy.DefaultStorage, certmagic.Default.Storage
+	caddy.DefaultStorage = &certmagic.FileStorage{Path: dir}
+	defer func() {
+		caddy.DefaultStorage, certmagic.Default.Storage = defaultStorage, certmagicStorage
+	}()
+
+	cfg := &caddy.Config{
+		AppsRaw: caddy.ModuleMap{probeAppID: json.RawMessage("{}")},
+	}
+	return caddy.Validate(cfg)
+}
+
+// probePanic is the error of a module panicking while probed.
+type probePanic struct {
+	value interface{}
+}
+
+func (p probePanic) Error() string {
+	return fmt.Sprintf("panic: %v", p.value)
+}
+
+// probeModule provisions and validates a new zero-valued instance of the
+// module in ctx, see withProbeContext. If provisioning succeeds,
+// provisioned is called with the instance. Panics are recovered and
+// returned as probePanic if there is no other error.
+// The instance is cleaned up before return.
+func probeModule(ctx caddy.Context, info caddy.ModuleInfo, provisioned func(caddy.Module)) (err error) {
+	defer func() {
+		if r := recover(); r != nil && err == nil {
+			err = probePanic{r}
+		}
+	}()
+
+	ctx, cancel := caddy.NewContext(ctx)
+	defer cancel()
+
+	mod := info.New()
+	if c, ok := mod.(caddy.CleanerUpper); ok {
+		// also after failed provisioning, which may have started
+		// goroutines
+		defer c.Cleanup()
+	}
+	if p, ok := mod.(caddy.Provisioner); ok {
+		if err := p.Provision(ctx); err != nil {
+			return err
+		}
+		if provisioned != nil {
+			provisioned(mod)
+		}
+	}
+	if v, ok := mod.(caddy.Validator); ok {
+		return v.Validate()
+	}
+	return nil
+}
+
+// probeModules probes the modules in the context of a throwaway config,
+// in order of module ID. See probe.
+func (g *generation) probeModules(ctx context.Context, interfaces map[string]*Interface) error {
+	var modules []string
+	for modName := range interfaces {
+		modules = append(modules, modName)
+	}
+	sort.Strings(modules)
+
+	var err error
+	probeErr := withProbeContext(func(probeCtx caddy.Context) {
+		for _, modName := range modules {
+			if err = ctx.Err(); err != nil {
+				return
+			}
+			g.probe(probeCtx, interfaces[modName], modName)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if probeErr != nil {
+		return fmt.Errorf("probing modules: %v", probeErr)
+	}
+	return nil
+}
+
+// probe probes a zero-valued instance of the module to derive the
+// required fields and defaults of the module f. Panics and errors other
+// than missing values are recorded in probeErrors.
+func (g *generation) probe(ctx caddy.Context, f *Interface, moduleID string) {
+	info, err := caddy.GetModule(moduleID)
+	if err != nil {
+		return
+	}
+	err = probeModule(ctx, info, func(mod caddy.Module) {
+		if g.defaults {
+			probeDefaults(f, info.New(), mod)
+		}
+	})
+	var panicked probePanic
+	if errors.As(err, &panicked) || (err != nil && !missingPattern.MatchString(err.Error())) {
+		g.probeErrors[moduleID] = err
+	}
+	if g.required {
+		probeRequired(f, err)
+	}
+}
+
+// probeRequired marks the fields of the module f required that are
+// reported missing by err, the error of probing the module. Panics are
+// no evidence of missing values, the requirements are unknown.
+func probeRequired(f *Interface, err error) {
+	var panicked probePanic
+	if err == nil || errors.As(err, &panicked) || !missingPattern.MatchString(err.Error()) {
+		return
+	}
+	for i, field := range f.Fields {
+		re := regexp.MustCompile(`(?i)(^|[^\w])` + regexp.QuoteMeta(field.Name) + `($|[^\w])`)
+		if re.MatchString(err.Error()) {
+			f.Fields[i].required = true
+		}
+	}
+}
+
+// probeDefaults sets the defaults of the fields of the module f to the
+// values of the provisioned instance that differ from the zero value.
+// Values are compared in their JSON representation.
+func probeDefaults(f *Interface, zero, provisioned caddy.Module) {
+	zeroProps, err := jsonProperties(zero)
+	if err != nil {
+		return
+	}
+	props, err := jsonProperties(provisioned)
+	if err != nil {
+		return
+	}
+	for i, field := range f.Fields {
+		value, ok := props[field.Name]
+		if ok && !bytes.Equal(value, zeroProps[field.Name]) {
+			f.Fields[i].defaultValue = value
+		}
+	}
+}
+
+// jsonProperties returns the compacted JSON properties of v.
+func jsonProperties(v interface{}) (map[string]json.RawMessage, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var props map[string]json.RawMessage
+	if err := json.Unmarshal(b, &props); err != nil {
+		return nil, err
+	}
+	for name, value := range props {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, value); err != nil {
+			return nil, err
+		}
+		props[name] = buf.Bytes()
+	}
+	return props, nil
+}
+
+// requiredFields returns the names of the required fields of the struct
+// f, the derived fields are overridden by requiredOverrides.
+func (f Interface) requiredFields() []string {
+	overrides := requiredOverrides[f.typeName]
+	var names []string
+	for _, field := range f.Fields {
+		required := field.required
+		if r, ok := overrides[field.Name]; ok {
+			required = r
+		}
+		if required {
+			names = append(names, field.Name)
+		}
+	}
+	return names
+}
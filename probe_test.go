@@ -0,0 +1,92 @@
+package jsonschema
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2"
+)
+
+type testProbeStorage struct {
+	Name string `json:"name,omitempty"`
+	Port int    `json:"port,omitempty"`
+}
+
+func (testProbeStorage) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{ID: "test.probe.storage", New: func() caddy.Module { return new(testProbeStorage) }}
+}
+
+func (p *testProbeStorage) Provision(ctx caddy.Context) error {
+	if err := ctx.Storage().Store("test", []byte("probe")); err != nil {
+		return err
+	}
+	p.Port = 80
+	return nil
+}
+
+func (p testProbeStorage) Validate() error {
+	if p.Name == "" {
+		return errors.New("name is required")
+	}
+	return nil
+}
+
+func (testProbeStorage) Cleanup() error {
+	testProbeCleanedUp = true
+	return nil
+}
+
+// testProbeCleanedUp is set when testProbeStorage is cleaned up.
+var testProbeCleanedUp bool
+
+type testProbePanic struct {
+	Name string `json:"name,omitempty"`
+}
+
+func (testProbePanic) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{ID: "test.probe.panic", New: func() caddy.Module { return new(testProbePanic) }}
+}
+
+func (testProbePanic) Provision(caddy.Context) error {
+	panic("name is required")
+}
+
+func init() {
+	caddy.RegisterModule(testProbeStorage{})
+	caddy.RegisterModule(testProbePanic{})
+}
+
+func TestProbe(t *testing.T) {
+	g := Generator{
+		Required: true,
+		Defaults: true,
+		Filter:   func(moduleID string) bool { return strings.HasPrefix(moduleID, "test.probe.") },
+	}
+	s, report, err := g.GenerateWithReport(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storage := s.Definitions["test.probe.storage"]
+	if len(storage.Required) != 1 || storage.Required[0] != "name" {
+		t.Errorf("got required %v, want [name]", storage.Required)
+	}
+	if def := string(storage.Properties["port"].Default); def != "80" {
+		t.Errorf("got port default %s, want 80", def)
+	}
+	if !testProbeCleanedUp {
+		t.Error("module not cleaned up")
+	}
+
+	if required := s.Definitions["test.probe.panic"].Required; len(required) > 0 {
+		t.Errorf("got required %v for panicking module", required)
+	}
+	if len(report.ProbeErrors) != 1 || report.ProbeErrors["test.probe.panic"] == nil {
+		t.Errorf("got probe errors %v, want test.probe.panic", report.ProbeErrors)
+	}
+	if _, ok := s.Definitions[probeAppID]; ok {
+		t.Error("probe app in schema")
+	}
+}
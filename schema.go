@@ -3,13 +3,11 @@ package jsonschema
 import (
 	"encoding/json"
 	"fmt"
+	"math/big"
+	"strconv"
 	"strings"
 )
 
-// rootSchema is the root schema where all sub schemas are added to.
-// This holds the root `Config` structure of Caddy JSON config.
-var rootSchema = NewSchema()
-
 // NewSchema creates a new Schema. It's primarily for the
 // convenience of initiating struct maps.
 func NewSchema() *Schema {
@@ -23,18 +21,29 @@ func NewSchema() *Schema {
 // JSON encoding of a Schema gives a valid JSON schema.
 // http://json-schema.org
 type Schema struct {
+	SchemaURI           string `json:"$schema,omitempty"`
+	ID                  string `json:"$id,omitempty"`
 	Title               string `json:"title,omitempty"`
 	Description         string `json:"description,omitempty"`
 	MarkdownDescription string `json:"markdownDescription,omitempty"`
 	Type                string `json:"type,omitempty"`
 	Ref                 string `json:"$ref,omitempty"`
 
-	ArrayItems           *Schema            `json:"items,omitempty"`
-	Definitions          map[string]*Schema `json:"definitions,omitempty"`
-	Properties           map[string]*Schema `json:"properties,omitempty"`
-	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
-	Required             []string           `json:"required,omitempty"`
-	Enum                 []string           `json:"enum,omitempty"`
+	ArrayItems            *Schema            `json:"items,omitempty"`
+	PrefixItems           []*Schema          `json:"prefixItems,omitempty"`
+	Definitions           map[string]*Schema `json:"definitions,omitempty"`
+	Properties            map[string]*Schema `json:"properties,omitempty"`
+	PatternProperties     map[string]*Schema `json:"patternProperties,omitempty"`
+	AdditionalProperties  *Schema            `json:"additionalProperties,omitempty"`
+	UnevaluatedProperties *Schema            `json:"unevaluatedProperties,omitempty"`
+	Required              []string           `json:"required,omitempty"`
+	Enum                  []string           `json:"enum,omitempty"`
+
+	Minimum          json.Number `json:"minimum,omitempty"`
+	Maximum          json.Number `json:"maximum,omitempty"`
+	ExclusiveMinimum json.Number `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum json.Number `json:"exclusiveMaximum,omitempty"`
+	MultipleOf       json.Number `json:"multipleOf,omitempty"`
 
 	AdditionalItems bool      `json:"additionalItems,omitempty"`
 	AllOf           []*Schema `json:"allOf,omitempty"`
@@ -45,13 +54,32 @@ type Schema struct {
 	Then *Schema `json:"then,omitempty"`
 	Else *Schema `json:"else,omitempty"`
 
-	Const string `json:"const,omitempty"`
+	Const    string            `json:"const,omitempty"`
+	Pattern  string            `json:"pattern,omitempty"`
+	Format   string            `json:"format,omitempty"`
+	Default  json.RawMessage   `json:"default,omitempty"`
+	Examples []json.RawMessage `json:"examples,omitempty"`
 
 	// internal use for docs generation
 	goPkg               string
 	description         string
 	markdownDescription string
 	nullable            bool
+	draft               Draft
+	reject              bool // false schema
+}
+
+// falseSchema returns a schema that rejects any value. It is
+// marshalled as false.
+func falseSchema() *Schema {
+	return &Schema{reject: true}
+}
+
+// isClosed reports if the object schema s rejects unknown properties,
+// with either additionalProperties or unevaluatedProperties.
+func isClosed(s *Schema) bool {
+	return (s.AdditionalProperties != nil && s.AdditionalProperties.reject) ||
+		(s.UnevaluatedProperties != nil && s.UnevaluatedProperties.reject)
 }
 
 func godocLink(pkg string) string {
@@ -100,7 +128,9 @@ func getType(typ string) string {
 	case "bool":
 		return "boolean"
 	case "int", "int8", "int16", "int32", "int64",
-		"uint", "uint8", "uint16", "uint32", "uint64":
+		"uint", "uint8", "uint16", "uint32", "uint64", "uintptr":
+		return "integer"
+	case "float32", "float64":
 		return "number"
 	case "slice":
 		return "array"
@@ -115,23 +145,149 @@ func getType(typ string) string {
 
 func (s *Schema) setType(typ string) {
 	s.Type = getType(typ)
+	if s.Type == "integer" {
+		s.Minimum, s.Maximum = integerRange(typ)
+	}
+}
+
+// integerRange returns the minimum and maximum values of the Go
+// integer type typ, derived from its bit width and signedness.
+func integerRange(typ string) (min, max json.Number) {
+	bits := strconv.IntSize
+	if n, err := strconv.Atoi(strings.TrimLeft(typ, "uint")); err == nil {
+		bits = n
+	}
+
+	one := big.NewInt(1)
+	if strings.HasPrefix(typ, "u") {
+		// 0 to 2^bits - 1
+		upper := new(big.Int).Lsh(one, uint(bits))
+		return json.Number("0"), json.Number(upper.Sub(upper, one).String())
+	}
+
+	// -2^(bits-1) to 2^(bits-1) - 1
+	upper := new(big.Int).Lsh(one, uint(bits-1))
+	lower := new(big.Int).Neg(upper)
+	return json.Number(lower.String()), json.Number(upper.Sub(upper, one).String())
 }
 
 func (s *Schema) setRef(moduleID string) {
 	s.Ref = "#/definitions/" + moduleID
 }
 
-// MarshalJSON allows to marshal Schema.Type as string or list
+// escapePointer escapes a JSON pointer reference token.
+func escapePointer(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+// unescapePointer unescapes a JSON pointer reference token.
+func unescapePointer(token string) string {
+	return strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+}
+
+// setDraft sets the draft s and its subschemas are marshalled for.
+func (s *Schema) setDraft(d Draft) {
+	s.walk(func(s *Schema) { s.draft = d })
+}
+
+// walk calls fn for s and all its subschemas. Subschemas shared
+// between schemas are visited once.
+func (s *Schema) walk(fn func(*Schema)) {
+	visited := map[*Schema]bool{}
+	var visit func(s *Schema)
+	visit = func(s *Schema) {
+		if s == nil || visited[s] {
+			return
+		}
+		visited[s] = true
+		fn(s)
+		for _, sub := range s.subschemas() {
+			visit(sub)
+		}
+	}
+	visit(s)
+}
+
+// subschemas returns the direct subschemas of s.
+func (s *Schema) subschemas() []*Schema {
+	subs := []*Schema{s.ArrayItems, s.AdditionalProperties, s.UnevaluatedProperties, s.If, s.Then, s.Else}
+	subs = append(subs, s.PrefixItems...)
+	subs = append(subs, s.AllOf...)
+	subs = append(subs, s.AnyOf...)
+	subs = append(subs, s.OneOf...)
+	for _, m := range []map[string]*Schema{s.Definitions, s.Properties, s.PatternProperties} {
+		for _, sub := range m {
+			subs = append(subs, sub)
+		}
+	}
+	return subs
+}
+
+// MarshalJSON marshals the schema with the keywords of its draft and
+// allows to marshal Schema.Type as string or list.
 func (s Schema) MarshalJSON() ([]byte, error) {
+	if s.reject {
+		return []byte("false"), nil
+	}
+
 	type Alias Schema
+	draft := s.draft
+	if draft == "" {
+		draft = Draft07
+	}
+	if draft == Draft07 && !s.nullable && len(s.PrefixItems) == 0 && s.UnevaluatedProperties == nil {
+		return json.Marshal(Alias(s))
+	}
+
+	if draft != Draft07 && strings.HasPrefix(s.Ref, "#/definitions/") {
+		s.Ref = "#/$defs/" + strings.TrimPrefix(s.Ref, "#/definitions/")
+	}
+
+	// keywords differing between drafts shadow the keywords of Alias
+	out := struct {
+		Alias
+		Type                  interface{}        `json:"type,omitempty"`
+		Definitions           map[string]*Schema `json:"definitions,omitempty"`
+		Defs                  map[string]*Schema `json:"$defs,omitempty"`
+		Items                 interface{}        `json:"items,omitempty"`
+		PrefixItems           []*Schema          `json:"prefixItems,omitempty"`
+		AdditionalItems       interface{}        `json:"additionalItems,omitempty"`
+		UnevaluatedProperties *Schema            `json:"unevaluatedProperties,omitempty"`
+	}{Alias: Alias(s)}
+
 	if s.nullable {
-		return json.Marshal(struct {
-			Type []string `json:"type"`
-			Alias
-		}{
-			Type:  []string{s.Type, "null"},
-			Alias: Alias(s),
-		})
-	}
-	return json.Marshal(Alias(s))
+		out.Type = []string{s.Type, "null"}
+	} else if s.Type != "" {
+		out.Type = s.Type
+	}
+	if s.ArrayItems != nil {
+		out.Items = s.ArrayItems
+	}
+
+	switch draft {
+	case Draft07:
+		// unevaluatedProperties is not supported, it is dropped
+		out.Definitions = s.Definitions
+	default:
+		out.Defs = s.Definitions
+		out.UnevaluatedProperties = s.UnevaluatedProperties
+	}
+
+	switch {
+	case draft == Draft202012:
+		// additionalItems is superseded by items after prefixItems
+		out.PrefixItems = s.PrefixItems
+	case len(s.PrefixItems) > 0:
+		// tuples are items lists before 2020-12
+		out.Items = s.PrefixItems
+		if s.ArrayItems != nil {
+			out.AdditionalItems = s.ArrayItems
+		} else if s.AdditionalItems {
+			out.AdditionalItems = true
+		}
+	case s.AdditionalItems:
+		out.AdditionalItems = true
+	}
+
+	return json.Marshal(out)
 }
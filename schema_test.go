@@ -0,0 +1,84 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// testDraftSchema returns a schema with the keywords differing between
+// drafts.
+func testDraftSchema() *Schema {
+	s := NewSchema()
+	s.Type = "object"
+
+	def := NewSchema()
+	def.Type = "object"
+	def.Properties["name"] = &Schema{Type: "string", nullable: true}
+	def.UnevaluatedProperties = falseSchema()
+	s.Definitions["def"] = def
+
+	s.Properties["ref"] = &Schema{Ref: "#/definitions/def"}
+	s.Properties["tuple"] = &Schema{
+		Type:        "array",
+		PrefixItems: []*Schema{{Type: "string"}, {Type: "integer"}},
+		ArrayItems:  &Schema{Type: "boolean"},
+	}
+	s.Properties["list"] = &Schema{Type: "array", ArrayItems: &Schema{Type: "string"}, AdditionalItems: true}
+	return s
+}
+
+func TestMarshalDrafts(t *testing.T) {
+	tests := []struct {
+		draft Draft
+		want  string
+	}{
+		{
+			draft: Draft07,
+			want: `{"type":"object",` +
+				`"definitions":{"def":{"type":"object","properties":{"name":{"type":["string","null"]}}}},` +
+				`"properties":{` +
+				`"list":{"type":"array","items":{"type":"string"},"additionalItems":true},` +
+				`"ref":{"$ref":"#/definitions/def"},` +
+				`"tuple":{"type":"array","items":[{"type":"string"},{"type":"integer"}],"additionalItems":{"type":"boolean"}}}}`,
+		},
+		{
+			draft: Draft201909,
+			want: `{"type":"object",` +
+				`"$defs":{"def":{"type":"object","properties":{"name":{"type":["string","null"]}},"unevaluatedProperties":false}},` +
+				`"properties":{` +
+				`"list":{"type":"array","items":{"type":"string"},"additionalItems":true},` +
+				`"ref":{"$ref":"#/$defs/def"},` +
+				`"tuple":{"type":"array","items":[{"type":"string"},{"type":"integer"}],"additionalItems":{"type":"boolean"}}}}`,
+		},
+		{
+			draft: Draft202012,
+			want: `{"type":"object",` +
+				`"$defs":{"def":{"type":"object","properties":{"name":{"type":["string","null"]}},"unevaluatedProperties":false}},` +
+				`"properties":{` +
+				`"list":{"type":"array","items":{"type":"string"}},` +
+				`"ref":{"$ref":"#/$defs/def"},` +
+				`"tuple":{"type":"array","items":{"type":"boolean"},"prefixItems":[{"type":"string"},{"type":"integer"}]}}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.draft), func(t *testing.T) {
+			s := testDraftSchema()
+			s.setDraft(tt.draft)
+			b, err := json.Marshal(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got, want interface{}
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got\n%s\nwant\n%s", b, tt.want)
+			}
+		})
+	}
+}
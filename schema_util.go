@@ -1,14 +1,81 @@
 package jsonschema
 
 import (
+	"context"
+	"encoding/json"
+	"reflect"
 	"strings"
 
 	"github.com/caddyserver/caddy/v2"
 )
 
-func generateSchema() error {
+// generation holds the state of a single schema generation.
+type generation struct {
+	filter       func(moduleID string) bool
+	placeholders *Placeholders
+	docs         *Docs
+	draft        Draft
+
+	// closed rejects unknown properties in structs.
+	closed bool
+
+	// required derives required properties.
+	required bool
+
+	// defaults derives defaults of module properties.
+	defaults bool
+
+	// examples configures the examples, dirExamples are the
+	// user-supplied examples mapped by module ID.
+	examples    *Examples
+	dirExamples map[string][]json.RawMessage
+
+	// inlineKeys maps namespaces to the inline keys of their module
+	// loaders.
+	inlineKeys map[string]string
+
+	// typeDefs are the definitions of Go struct types shared between
+	// properties, mapped by definition name. documented tracks the
+	// definitions with docs added.
+	typeDefs   map[string]*Schema
+	documented map[*Schema]bool
+
+	// populating maps the struct types being populated to the
+	// references to their definitions, for recursive types.
+	populating map[reflect.Type]string
+
+	// unsupportedLoaders are the module loader fields of unsupported
+	// types.
+	unsupportedLoaders map[string]bool
+
+	// probeErrors are the errors of probing modules, mapped by module
+	// ID.
+	probeErrors map[string]error
+
+	// moduleMap is map of namespaces to namespace modules.
+	// It is used by module loaders to identify modules in namespace.
+	moduleMap map[string]Modules
+
+	// flatModuleMap is a flat map of module namespace to module
+	// without nesting.
+	flatModuleMap Modules
+}
+
+func (g *generation) generate(ctx context.Context) (*Schema, error) {
+	var err error
+	if g.dirExamples, err = g.examples.load(); err != nil {
+		return nil, err
+	}
+
 	// fetch all caddy modules available in current build
 	for _, mod := range caddy.Modules() {
+		if mod == probeAppID {
+			continue
+		}
+		if g.filter != nil && !g.filter(mod) {
+			continue
+		}
+
 		split := strings.Split(mod, ".")
 
 		parent := "" // top level modules
@@ -20,11 +87,11 @@ func generateSchema() error {
 
 		info, err := caddy.GetModule(mod)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		if moduleMap[parent] == nil {
-			moduleMap[parent] = Modules{}
+		if g.moduleMap[parent] == nil {
+			g.moduleMap[parent] = Modules{}
 		}
 
 		newInfo := info.New()
@@ -34,32 +101,63 @@ func generateSchema() error {
 			Interface: Interface{
 				Name:   name,
 				Module: mod,
+				inline: true,
+				gen:    g,
 			},
 		}
 
-		moduleMap[parent][name] = module
-		flatModuleMap[mod] = module
+		g.moduleMap[parent][name] = module
+		g.flatModuleMap[mod] = module
 	}
 
 	// schema generation
 	// use separate loop to ensure module list has populated.
+	var rootSchema *Schema
 	{
 		// all module definitions
 		definitions := map[string]*Schema{}
 
-		for modName, module := range flatModuleMap {
-			module.Interface.populate(module.Type)
-			schema := module.Interface.toSchema()
-			if doc, ok := flatCaddyDocMap[modName]; ok {
-				addDocToSchema(schema, doc.Result.Structure)
+		// populate all modules before conversion to schema,
+		// module loaders register the inline keys.
+		interfaces := map[string]*Interface{}
+		for modName, module := range g.flatModuleMap {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
+			f := module.Interface
+			f.populate(module.Type)
+			interfaces[modName] = &f
+		}
+		if g.required || g.defaults {
+			if err := g.probeModules(ctx, interfaces); err != nil {
+				return nil, err
 			}
-			definitions[modName] = schema
 		}
 
 		// full config
-		configField := Interface{}
+		configField := Interface{inline: true, gen: g}
 		configField.populate(caddy.Config{})
+
+		for modName, f := range interfaces {
+			schema := f.toSchema()
+			g.addInlineKey(schema, modName)
+			if doc, ok := g.docs.Modules[modName]; ok && doc != nil {
+				g.addDocToSchema(schema, doc.Result.Structure)
+			}
+			g.addExamples(schema, modName)
+			definitions[modName] = schema
+		}
+
 		rootSchema = configField.toSchema()
+		if g.docs.Root.Result.Structure != nil {
+			g.addDocToSchema(rootSchema, g.docs.Root.Result.Structure)
+		}
+
+		// shared Go types
+		for name, def := range g.typeDefs {
+			definitions[name] = def
+		}
 		rootSchema.Definitions = definitions
 
 		// in case this schema is incomplete, support additional custom items.
@@ -68,19 +166,61 @@ func generateSchema() error {
 		// docs
 		rootSchema.Title = "Caddy v2 autogenerated JSON schema  \nhttps://github.com/abiosoft/caddy-json-schema"
 		rootSchema.Type = "object"
-		if rootDocAPIResp.Result.Structure != nil {
-			addDocToSchema(rootSchema, rootDocAPIResp.Result.Structure)
-		}
 
 	}
 
-	return nil
+	return rootSchema, nil
+}
+
+// addInlineKey adds the inline key to the closed schema s of the module.
+// Module loaders with inline keys set the key in the module object.
+func (g *generation) addInlineKey(s *Schema, moduleID string) {
+	if !isClosed(s) {
+		return
+	}
+
+	namespace, name := "", moduleID
+	if i := strings.LastIndex(moduleID, "."); i >= 0 {
+		namespace, name = moduleID[:i], moduleID[i+1:]
+	}
+	key, ok := g.inlineKeys[namespace]
+	if !ok {
+		return
+	}
+	if _, ok := s.Properties[key]; ok {
+		return
+	}
+
+	keySchema := NewSchema()
+	keySchema.setType("string")
+	keySchema.Const = name
+	keySchema.Description = description(key, "string", moduleID)
+	keySchema.MarkdownDescription = markdownDescription(key, "string", moduleID)
+	s.Properties[key] = keySchema
 }
 
-func addDocToSchema(s *Schema, doc *DocStruct) {
+// addExamples adds the Caddyfile and user-supplied examples to the
+// schema s of the module.
+func (g *generation) addExamples(s *Schema, moduleID string) {
+	if g.examples == nil {
+		return
+	}
+	namespace := ""
+	if i := strings.LastIndex(moduleID, "."); i >= 0 {
+		namespace = moduleID[:i]
+	}
+	s.Examples = append(s.Examples, caddyfileExamples(moduleID, g.inlineKeys[namespace])...)
+	s.Examples = append(s.Examples, g.dirExamples[moduleID]...)
+}
+
+// addDocToSchema adds the docs to s. If examples are configured, JSON
+// blocks in the docs are added as examples. The docs of a shared Go type
+// are added to its definition at the first reference.
+func (g *generation) addDocToSchema(s *Schema, doc *DocStruct) {
 	if s == nil || doc == nil {
 		return
 	}
+	examples := g.examples != nil
 
 	desc := func(description, pkg, doc string) string {
 		pkg = godocLink(pkg)
@@ -107,9 +247,28 @@ func addDocToSchema(s *Schema, doc *DocStruct) {
 		s.MarkdownDescription = mdDesc(s.markdownDescription, d.Package, d.Doc)
 	}
 
+	// shared Go type, examples take the shape of the definition
+	def := g.typeDefs[typeDefName(s.Ref)]
+	shape := s
+	if def != nil {
+		shape = def
+	}
+
 	// set only if non-empty, parent may have set the doc if empty
 	if doc.Doc != "" {
 		setDesc(s, doc)
+		if examples {
+			s.Examples = append(s.Examples, docExamples(shape, doc.Doc)...)
+		}
+	}
+
+	if def != nil && !g.documented[def] {
+		g.documented[def] = true
+		// the doc describes the referencing property, keep the
+		// description and examples of the type
+		desc, mdDesc, examples := def.Description, def.MarkdownDescription, def.Examples
+		g.addDocToSchema(def, doc)
+		def.Description, def.MarkdownDescription, def.Examples = desc, mdDesc, examples
 	}
 
 	switch doc.Type {
@@ -120,7 +279,8 @@ func addDocToSchema(s *Schema, doc *DocStruct) {
 				if field.Value.Doc == "" {
 					setDesc(s.Properties[field.Key], field)
 				}
-				addDocToSchema(s.Properties[field.Key], doc.StructFields[i].Value)
+				g.addDocToSchema(s.Properties[field.Key], doc.StructFields[i].Value)
+				setEnum(s.Properties[field.Key], docEnum(doc.Package, field.Key, field.Doc))
 			}
 		}
 	case "array":
@@ -128,14 +288,14 @@ func addDocToSchema(s *Schema, doc *DocStruct) {
 			if doc.Elems.Doc != "" {
 				setDesc(s, doc.Elems) // use items doc for parent
 			}
-			addDocToSchema(s.ArrayItems, doc.Elems)
+			g.addDocToSchema(s.ArrayItems, doc.Elems)
 		}
 	case "map":
 		if s.AdditionalProperties != nil && doc.Elems != nil {
 			if doc.Elems.Doc != "" {
 				setDesc(s, doc.Elems) // use items doc for parent
 			}
-			addDocToSchema(s.AdditionalProperties, doc.Elems)
+			g.addDocToSchema(s.AdditionalProperties, doc.Elems)
 		}
 	default:
 		// everything else has no nesting
@@ -21,12 +21,12 @@ const (
 // M is a convenience wrapper for JSON object.
 type M map[string]interface{}
 
-func writeToFile(w schemaWriter) error {
+func writeToFile(w schemaWriter, s *Schema) error {
 	if err := w.Prepare(); err != nil {
 		return err
 	}
 
-	return w.Write()
+	return w.Write(s)
 }
 
 var _ schemaWriter = (*vscodeWriter)(nil)
@@ -34,14 +34,14 @@ var _ schemaWriter = (*basicWriter)(nil)
 
 type schemaWriter interface {
 	Prepare() error
-	Write() error
+	Write(*Schema) error
 }
 
 type basicWriter struct{}
 
 func (b basicWriter) Prepare() error { return nil }
-func (b basicWriter) Write() error {
-	return jsonToFile(rootSchema, config.File, filePerm)
+func (b basicWriter) Write(s *Schema) error {
+	return jsonToFile(s, config.File, filePerm)
 }
 
 type file struct {
@@ -207,8 +207,8 @@ func (v *vscodeWriter) setVsConfig() error {
 	return nil
 }
 
-func (v *vscodeWriter) Write() error {
-	err := jsonToFile(rootSchema, v.schema.filename, v.schema.perm)
+func (v *vscodeWriter) Write(s *Schema) error {
+	err := jsonToFile(s, v.schema.filename, v.schema.perm)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,198 @@
+package jsonschema
+
+import (
+	"encoding"
+	"encoding/json"
+	"math/big"
+	"net"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/caddyserver/caddy/v2/modules/caddytls"
+)
+
+// JSONSchemaProvider is implemented by types that provide their own
+// JSON schema. Module authors can implement it on module types and
+// field types whose JSON representation differs from the Go type
+// e.g. types with custom JSON unmarshaling.
+//
+// JSONSchema is called on a zero value of the type and must return a
+// new Schema on every call. If it returns nil, the schema is generated
+// from the Go type.
+type JSONSchemaProvider interface {
+	JSONSchema() *Schema
+}
+
+// providedSchema returns the schema provided by t if it implements
+// JSONSchemaProvider with either value or pointer receiver.
+func providedSchema(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Ptr {
+		// pointers are dereferenced during populate
+		return nil
+	}
+	if p, ok := reflect.New(t).Interface().(JSONSchemaProvider); ok {
+		return p.JSONSchema()
+	}
+	return nil
+}
+
+// knownType is a Go type with a predefined schema, for types
+// whose JSON representation cannot be deduced from the Go type.
+type knownType struct {
+	// name is the type name used in descriptions.
+	name string
+
+	// schema returns a new schema for the type.
+	schema func() *Schema
+}
+
+// knownTypes is the registry of special-cased Go types.
+var knownTypes = map[reflect.Type]knownType{
+	reflect.TypeOf(caddy.Duration(0)):        {name: "duration", schema: durationSchema},
+	reflect.TypeOf(caddyhttp.WeakString("")): {name: "weak string", schema: weakStringSchema},
+
+	// decoded case insensitively
+	reflect.TypeOf(caddytls.PublicKeyAlgorithm(0)): {name: "string", schema: caseInsensitiveSchema("rsa", "dsa", "ecdsa")},
+	// unexported big integer decoded from a decimal string
+	reflect.TypeOf(caddytls.CustomCertSelectionPolicy{}.SerialNumber).Elem(): {name: "integer string", schema: integerStringSchema},
+
+	// standard library types with custom unmarshalling
+	reflect.TypeOf(time.Time{}):     {name: "date-time", schema: formatSchema("date-time")},
+	reflect.TypeOf(net.IP{}):        {name: "ip", schema: ipSchema},
+	reflect.TypeOf(big.Int{}):       {name: "integer", schema: bigIntSchema},
+	reflect.TypeOf(json.Number("")): {name: "number", schema: numberSchema},
+}
+
+// customRepresentation returns the schema of types decoding JSON
+// themselves, and the type name used in descriptions. Text
+// unmarshalers are decoded from JSON strings, the representation of
+// other JSON unmarshalers is unknown and any value is accepted.
+// Structs with JSON unmarshalling are left to populate, their JSON
+// tagged fields may reflect the representation.
+func customRepresentation(t reflect.Type) (string, *Schema) {
+	if t.Kind() == reflect.Ptr {
+		// pointers are dereferenced during populate
+		return "", nil
+	}
+	p := reflect.PtrTo(t)
+	switch {
+	case p.Implements(reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()):
+		if t.Kind() == reflect.Struct {
+			return "", nil
+		}
+		return "any", NewSchema()
+	case p.Implements(reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()):
+		s := NewSchema()
+		s.setType("string")
+		return "string", s
+	}
+	return "", nil
+}
+
+// durationPattern matches the duration syntax accepted by
+// caddy.ParseDuration i.e. time.ParseDuration with the addition
+// of the day (d) unit.
+const durationPattern = `^[-+]?(0|(([0-9]+(\.[0-9]*)?|\.[0-9]+)(ns|us|µs|μs|ms|s|m|h|d))+)$`
+
+// durationSchema is the schema for caddy.Duration, which can be
+// an integer in nanoseconds or a duration string.
+func durationSchema() *Schema {
+	integer := NewSchema()
+	integer.setType("int64")
+	integer.Description = "duration in nanoseconds"
+
+	str := NewSchema()
+	str.setType("string")
+	str.Pattern = durationPattern
+	str.Description = "duration string e.g. 500ms, 1.5h, 1d"
+
+	s := NewSchema()
+	s.OneOf = []*Schema{integer, str}
+	return s
+}
+
+// weakStringSchema is the schema for caddyhttp.WeakString, which can be
+// a string or any scalar decoded to its string form.
+func weakStringSchema() *Schema {
+	s := NewSchema()
+	for _, typ := range []string{"string", "number", "boolean"} {
+		sub := NewSchema()
+		sub.Type = typ
+		s.OneOf = append(s.OneOf, sub)
+	}
+	return s
+}
+
+// formatSchema returns a function returning the schema for strings of
+// the format.
+func formatSchema(format string) func() *Schema {
+	return func() *Schema {
+		s := NewSchema()
+		s.setType("string")
+		s.Format = format
+		return s
+	}
+}
+
+// ipSchema is the schema for net.IP, an IPv4 or IPv6 address string.
+func ipSchema() *Schema {
+	s := NewSchema()
+	s.setType("string")
+	s.AnyOf = []*Schema{formatSchema("ipv4")(), formatSchema("ipv6")()}
+	return s
+}
+
+// bigIntSchema is the schema for big.Int, an integer of any size.
+func bigIntSchema() *Schema {
+	s := NewSchema()
+	s.Type = "integer"
+	return s
+}
+
+// numberSchema is the schema for json.Number.
+func numberSchema() *Schema {
+	s := NewSchema()
+	s.setType("float64")
+	return s
+}
+
+// caseInsensitiveSchema returns a function returning the schema for
+// strings of the values in any case. JSON schema patterns have no
+// flags, letters are matched by character classes.
+func caseInsensitiveSchema(values ...string) func() *Schema {
+	alternatives := make([]string, len(values))
+	for i, v := range values {
+		var b strings.Builder
+		for _, r := range v {
+			lower, upper := unicode.ToLower(r), unicode.ToUpper(r)
+			if lower == upper {
+				b.WriteString(regexp.QuoteMeta(string(r)))
+				continue
+			}
+			b.WriteString("[" + string(lower) + string(upper) + "]")
+		}
+		alternatives[i] = b.String()
+	}
+	pattern := "^(?:" + strings.Join(alternatives, "|") + ")$"
+
+	return func() *Schema {
+		s := NewSchema()
+		s.setType("string")
+		s.Pattern = pattern
+		return s
+	}
+}
+
+// integerStringSchema is the schema for integers of any size encoded
+// as decimal strings.
+func integerStringSchema() *Schema {
+	s := NewSchema()
+	s.setType("string")
+	s.Pattern = `^-?[0-9]+$`
+	return s
+}
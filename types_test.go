@@ -0,0 +1,72 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/caddyserver/caddy/v2/modules/caddytls"
+)
+
+// testRawList decodes JSON itself, its representation is unknown.
+type testRawList []string
+
+func (*testRawList) UnmarshalJSON([]byte) error { return nil }
+
+// testLevel is decoded from a JSON string.
+type testLevel int
+
+func (*testLevel) UnmarshalText([]byte) error { return nil }
+
+type testTypes struct {
+	Time      time.Time                   `json:"time,omitempty"`
+	IP        net.IP                      `json:"ip,omitempty"`
+	Raw       testRawList                 `json:"raw,omitempty"`
+	Level     testLevel                   `json:"level,omitempty"`
+	Expr      caddyhttp.MatchExpression   `json:"expr,omitempty"`
+	Algorithm caddytls.PublicKeyAlgorithm `json:"algorithm,omitempty"`
+	Number    json.Number                 `json:"number,omitempty"`
+}
+
+func (testTypes) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{ID: "test.types", New: func() caddy.Module { return new(testTypes) }}
+}
+
+func init() {
+	caddy.RegisterModule(testTypes{})
+}
+
+func TestCustomTypes(t *testing.T) {
+	s := generateTest(t, Generator{}, "test.types")
+	props := s.Definitions["test.types"].Properties
+
+	tests := []struct {
+		property, typ, format string
+	}{
+		{"time", "string", "date-time"},
+		{"ip", "string", ""},
+		{"raw", "", ""},
+		{"level", "string", ""},
+		{"expr", "string", ""},
+		{"algorithm", "string", ""},
+		{"number", "number", ""},
+	}
+	for _, tt := range tests {
+		p := props[tt.property]
+		if p == nil {
+			t.Errorf("%s: missing", tt.property)
+			continue
+		}
+		if p.Type != tt.typ || p.Format != tt.format {
+			t.Errorf("%s: got type %q format %q, want %q %q", tt.property, p.Type, p.Format, tt.typ, tt.format)
+		}
+	}
+
+	validateTest(t, s, "test.types", `{"time":"2021-01-02T15:04:05Z","ip":"::1","raw":{"any":1},"level":"debug","expr":"{path} == '/'","algorithm":"ECDSA","number":1.5}`, nil)
+	validateTest(t, s, "test.types", `{"algorithm":"ed25519"}`, []testValidationError{
+		{1, 2, "/algorithm", "must match pattern"},
+	})
+}
@@ -0,0 +1,537 @@
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ValidationError is a violation of the schema in a JSON document.
+type ValidationError struct {
+	// Path is the JSON pointer to the invalid value.
+	Path string
+
+	// Message describes the violation.
+	Message string
+
+	// Line and Column are the 1-based position of the invalid value
+	// in the document.
+	Line, Column int
+}
+
+func (e ValidationError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "/"
+	}
+	if e.Line > 0 {
+		return fmt.Sprintf("%d:%d: %s: %s", e.Line, e.Column, path, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", path, e.Message)
+}
+
+// Validate validates the JSON document b against the schema s.
+// Schema violations are returned as ValidationErrors sorted by position,
+// an error is only returned if b is not valid JSON.
+//
+// The validator supports the keywords in schemas produced by Generator,
+// $ref is resolved against the definitions of s.
+func (s *Schema) Validate(b []byte) ([]ValidationError, error) {
+	return validateDocument(&validator{root: s}, b)
+}
+
+// ValidateStrict is like Validate but additionally rejects unknown
+// properties, i.e. properties of objects with known properties that are
+// not defined by the schema. Caddy ignores unknown properties when
+// loading a config.
+func (s *Schema) ValidateStrict(b []byte) ([]ValidationError, error) {
+	return validateDocument(&validator{root: s, strict: true}, b)
+}
+
+// validateDocument validates the JSON document b with v.
+func validateDocument(v *validator, b []byte) ([]ValidationError, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("invalid JSON: unexpected data after top-level value")
+	}
+
+	errs := v.validateValue(v.root, doc, "")
+
+	// positions
+	offsets := jsonOffsets(b)
+	for i := range errs {
+		if offset, ok := offsets[errs[i].Path]; ok {
+			errs[i].Line, errs[i].Column = lineColumn(b, offset)
+		}
+	}
+
+	// dedupe and sort by position
+	seen := map[string]bool{}
+	unique := errs[:0]
+	for _, e := range errs {
+		key := e.Path + "\x00" + e.Message
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, e)
+		}
+	}
+	sort.SliceStable(unique, func(i, j int) bool {
+		if unique[i].Line != unique[j].Line {
+			return unique[i].Line < unique[j].Line
+		}
+		return unique[i].Column < unique[j].Column
+	})
+
+	return unique, nil
+}
+
+// idKey is the key identifying config objects in the admin API. Caddy
+// removes it from objects before loading the config.
+const idKey = "@id"
+
+// validator validates JSON values against a root schema.
+type validator struct {
+	root     *Schema
+	strict   bool // reject unknown properties
+	patterns map[string]*regexp.Regexp
+}
+
+// evaluation is the result of applying a schema and its in-place
+// subschemas to an object.
+type evaluation struct {
+	// props are the evaluated properties.
+	props map[string]bool
+
+	// closed is set if any of the schemas lists properties without
+	// allowing additional ones i.e. the object is a struct.
+	closed bool
+}
+
+// validateValue validates the JSON value inst at path against s.
+// In strict mode, unknown properties of inst are rejected, except idKey.
+func (v *validator) validateValue(s *Schema, inst interface{}, path string) []ValidationError {
+	errs, ev := v.validate(s, inst, path)
+
+	obj, ok := inst.(map[string]interface{})
+	if !v.strict || !ok || !ev.closed {
+		return errs
+	}
+	for name := range obj {
+		if !ev.props[name] && name != idKey {
+			errs = append(errs, ValidationError{
+				Path:    path + "/" + escapePointer(name),
+				Message: fmt.Sprintf("unknown property %q", name),
+			})
+		}
+	}
+	return errs
+}
+
+// validate validates the JSON value inst at path against s. It also
+// returns the evaluation of s and its in-place subschemas.
+func (v *validator) validate(s *Schema, inst interface{}, path string) ([]ValidationError, evaluation) {
+	evaluated := evaluation{props: map[string]bool{}}
+	if s == nil {
+		return nil, evaluated
+	}
+
+	var errs []ValidationError
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	if s.reject {
+		fail("not allowed")
+		return errs, evaluated
+	}
+	// merge errors and evaluation of in-place subschemas
+	merge := func(e []ValidationError, ev evaluation) {
+		errs = append(errs, e...)
+		for p := range ev.props {
+			evaluated.props[p] = true
+		}
+		evaluated.closed = evaluated.closed || ev.closed
+	}
+
+	if s.Ref != "" {
+		ref, err := v.resolve(s.Ref)
+		if err != nil {
+			fail("%v", err)
+		} else {
+			merge(v.validate(ref, inst, path))
+		}
+	}
+
+	if s.Type != "" && !typeMatches(s.Type, inst) && !(s.nullable && inst == nil) {
+		fail("expected %s, found %s", s.Type, jsonType(inst))
+		// further keywords are meaningless for the wrong type
+		return errs, evaluated
+	}
+
+	if len(s.Enum) > 0 {
+		str, ok := inst.(string)
+		if !ok || !containsString(s.Enum, str) {
+			fail("must be one of %s", strings.Join(quoteAll(s.Enum), ", "))
+		}
+	}
+	if s.Const != "" && inst != s.Const {
+		fail("must be %q", s.Const)
+	}
+
+	switch inst := inst.(type) {
+	case string:
+		if s.Pattern != "" {
+			if re, err := v.pattern(s.Pattern); err != nil {
+				fail("invalid pattern in schema: %v", err)
+			} else if !re.MatchString(inst) {
+				fail("must match pattern %s", s.Pattern)
+			}
+		}
+
+	case json.Number:
+		v.validateNumber(s, inst, fail)
+
+	case []interface{}:
+		for i, item := range inst {
+			items := s.ArrayItems
+			if i < len(s.PrefixItems) {
+				items = s.PrefixItems[i]
+			}
+			if items != nil {
+				errs = append(errs, v.validateValue(items, item, path+"/"+strconv.Itoa(i))...)
+			}
+		}
+
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := inst[name]; !ok {
+				fail("missing required property %q", name)
+			}
+		}
+		for name, value := range inst {
+			listed := false
+			if prop, ok := s.Properties[name]; ok {
+				errs = append(errs, v.validateValue(prop, value, path+"/"+escapePointer(name))...)
+				listed = true
+			}
+			for pattern, prop := range s.PatternProperties {
+				re, err := v.pattern(pattern)
+				if err != nil {
+					fail("invalid pattern in schema: %v", err)
+					continue
+				}
+				if re.MatchString(name) {
+					errs = append(errs, v.validateValue(prop, value, path+"/"+escapePointer(name))...)
+					listed = true
+				}
+			}
+			if !listed && s.AdditionalProperties != nil {
+				errs = append(errs, v.validateUnlisted(s.AdditionalProperties, name, value, path)...)
+				listed = true
+			}
+			if listed {
+				evaluated.props[name] = true
+			}
+		}
+		if len(s.Properties) > 0 && s.AdditionalProperties == nil {
+			evaluated.closed = true
+		}
+	}
+
+	for _, sub := range s.AllOf {
+		merge(v.validate(sub, inst, path))
+	}
+
+	if len(s.AnyOf) > 0 {
+		var branchErrs []ValidationError
+		matched := false
+		for _, sub := range s.AnyOf {
+			e, ev := v.validate(sub, inst, path)
+			if len(e) == 0 {
+				matched = true
+				merge(nil, ev)
+				continue
+			}
+			branchErrs = append(branchErrs, e[0])
+		}
+		if !matched {
+			fail("must match at least one of: %s", branchMessages(branchErrs))
+		}
+	}
+
+	if len(s.OneOf) > 0 {
+		var branchErrs []ValidationError
+		matches := 0
+		for _, sub := range s.OneOf {
+			e, ev := v.validate(sub, inst, path)
+			if len(e) == 0 {
+				matches++
+				merge(nil, ev)
+				continue
+			}
+			branchErrs = append(branchErrs, e[0])
+		}
+		switch {
+		case matches == 0:
+			fail("must match one of: %s", branchMessages(branchErrs))
+		case matches > 1:
+			fail("must match exactly one schema, matched %d", matches)
+		}
+	}
+
+	if s.If != nil {
+		e, ev := v.validate(s.If, inst, path)
+		if len(e) == 0 {
+			merge(nil, ev)
+			if s.Then != nil {
+				merge(v.validate(s.Then, inst, path))
+			}
+		} else if s.Else != nil {
+			merge(v.validate(s.Else, inst, path))
+		}
+	}
+
+	// unevaluatedProperties applies after all in-place subschemas
+	if obj, ok := inst.(map[string]interface{}); ok && s.UnevaluatedProperties != nil {
+		for name, value := range obj {
+			if !evaluated.props[name] {
+				errs = append(errs, v.validateUnlisted(s.UnevaluatedProperties, name, value, path)...)
+				evaluated.props[name] = true
+			}
+		}
+	}
+
+	return errs, evaluated
+}
+
+// validateUnlisted validates the property name of the object at path,
+// which is not listed in its properties, against s.
+func (v *validator) validateUnlisted(s *Schema, name string, value interface{}, path string) []ValidationError {
+	propPath := path + "/" + escapePointer(name)
+	if s.reject {
+		return []ValidationError{{Path: propPath, Message: fmt.Sprintf("unknown property %q", name)}}
+	}
+	return v.validateValue(s, value, propPath)
+}
+
+// validateNumber validates the numeric keywords of s against n.
+func (v *validator) validateNumber(s *Schema, n json.Number, fail func(string, ...interface{})) {
+	value, ok := new(big.Rat).SetString(n.String())
+	if !ok {
+		fail("invalid number %s", n)
+		return
+	}
+	compare := func(limit json.Number) (int, bool) {
+		if limit == "" {
+			return 0, false
+		}
+		l, ok := new(big.Rat).SetString(limit.String())
+		if !ok {
+			return 0, false
+		}
+		return value.Cmp(l), true
+	}
+
+	if c, ok := compare(s.Minimum); ok && c < 0 {
+		fail("must be >= %s", s.Minimum)
+	}
+	if c, ok := compare(s.Maximum); ok && c > 0 {
+		fail("must be <= %s", s.Maximum)
+	}
+	if c, ok := compare(s.ExclusiveMinimum); ok && c <= 0 {
+		fail("must be > %s", s.ExclusiveMinimum)
+	}
+	if c, ok := compare(s.ExclusiveMaximum); ok && c >= 0 {
+		fail("must be < %s", s.ExclusiveMaximum)
+	}
+	if s.MultipleOf != "" {
+		if m, ok := new(big.Rat).SetString(s.MultipleOf.String()); ok && m.Sign() != 0 {
+			if !new(big.Rat).Quo(value, m).IsInt() {
+				fail("must be a multiple of %s", s.MultipleOf)
+			}
+		}
+	}
+}
+
+// resolve resolves a local reference to the root schema or a definition
+// of the root schema.
+func (v *validator) resolve(ref string) (*Schema, error) {
+	if ref == "#" {
+		return v.root, nil
+	}
+	for _, prefix := range []string{"#/definitions/", "#/$defs/"} {
+		if strings.HasPrefix(ref, prefix) {
+			name := unescapePointer(strings.TrimPrefix(ref, prefix))
+			if def, ok := v.root.Definitions[name]; ok {
+				return def, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("unresolved reference %s in schema", ref)
+}
+
+// pattern returns the compiled regular expression for pattern.
+func (v *validator) pattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := v.patterns[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if v.patterns == nil {
+		v.patterns = map[string]*regexp.Regexp{}
+	}
+	v.patterns[pattern] = re
+	return re, nil
+}
+
+// jsonType returns the JSON schema type of the decoded JSON value.
+func jsonType(inst interface{}) string {
+	switch inst := inst.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if r, ok := new(big.Rat).SetString(inst.String()); ok && r.IsInt() {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", inst)
+}
+
+// typeMatches reports if the decoded JSON value is of the JSON schema type.
+func typeMatches(typ string, inst interface{}) bool {
+	actual := jsonType(inst)
+	return actual == typ || (typ == "number" && actual == "integer")
+}
+
+func branchMessages(errs []ValidationError) string {
+	var msgs []string
+	for _, e := range errs {
+		msgs = append(msgs, e.Message)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func quoteAll(list []string) []string {
+	quoted := make([]string, len(list))
+	for i, s := range list {
+		quoted[i] = strconv.Quote(s)
+	}
+	return quoted
+}
+
+// jsonOffsets maps the JSON pointers of all values in the JSON document b
+// to their byte offsets. Object members are mapped to the offset of
+// their key.
+func jsonOffsets(b []byte) map[string]int {
+	offsets := map[string]int{}
+
+	type container struct {
+		path   string
+		object bool
+		index  int    // next array index
+		key    bool   // expecting an object key
+		member string // path of the current object member
+	}
+	var stack []*container
+
+	// tokenStart returns the offset of the token at or after offset,
+	// skipping whitespace and separators.
+	tokenStart := func(offset int) int {
+		for offset < len(b) && bytes.IndexByte([]byte(" \t\r\n,:"), b[offset]) >= 0 {
+			offset++
+		}
+		return offset
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	for {
+		start := tokenStart(int(dec.InputOffset()))
+		tok, err := dec.Token()
+		if err != nil {
+			return offsets
+		}
+
+		var top *container
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+
+		if d, ok := tok.(json.Delim); ok && (d == '}' || d == ']') {
+			stack = stack[:len(stack)-1]
+			if len(stack) > 0 && stack[len(stack)-1].object {
+				// member value complete
+				stack[len(stack)-1].key = true
+			}
+			continue
+		}
+
+		path := ""
+		switch {
+		case top == nil:
+			offsets[path] = start
+		case top.object && top.key:
+			top.member = top.path + "/" + escapePointer(tok.(string))
+			top.key = false
+			offsets[top.member] = start
+			continue
+		case top.object:
+			path = top.member
+		default:
+			path = top.path + "/" + strconv.Itoa(top.index)
+			top.index++
+			offsets[path] = start
+		}
+
+		if d, ok := tok.(json.Delim); ok {
+			stack = append(stack, &container{path: path, object: d == '{', key: d == '{'})
+		} else if top != nil && top.object {
+			// scalar member value complete
+			top.key = true
+		}
+	}
+}
+
+// lineColumn returns the 1-based line and column of offset in b.
+func lineColumn(b []byte, offset int) (line, column int) {
+	if offset > len(b) {
+		offset = len(b)
+	}
+	line = 1 + bytes.Count(b[:offset], []byte("\n"))
+	column = offset + 1
+	if i := bytes.LastIndexByte(b[:offset], '\n'); i >= 0 {
+		column = offset - i
+	}
+	return line, column
+}
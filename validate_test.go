@@ -0,0 +1,168 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2"
+	_ "github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+type testValidate struct {
+	Timeout     caddy.Duration    `json:"timeout,omitempty"`
+	Retries     int               `json:"retries,omitempty"`
+	HandlersRaw []json.RawMessage `json:"handle,omitempty" caddy:"namespace=http.handlers inline_key=handler"`
+}
+
+func (testValidate) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{ID: "test.validate", New: func() caddy.Module { return new(testValidate) }}
+}
+
+func init() {
+	caddy.RegisterModule(testValidate{})
+}
+
+// testValidationError is an expected validation error, the message
+// is matched as a substring.
+type testValidationError struct {
+	line, column int
+	path         string
+	message      string
+}
+
+// validateTest validates the config against the definition of the
+// module in s and compares the errors.
+func validateTest(t *testing.T, s *Schema, module, config string, want []testValidationError) {
+	t.Helper()
+	root := NewSchema()
+	root.Ref = "#/definitions/" + module
+	root.Definitions = s.Definitions
+
+	errs, err := root.Validate([]byte(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("%s: got errors %v, want %d", config, errs, len(want))
+	}
+	for i, e := range errs {
+		w := want[i]
+		if e.Line != w.line || e.Column != w.column || e.Path != w.path || !strings.Contains(e.Message, w.message) {
+			t.Errorf("%s: got error %q, want %d:%d: %s: %s", config, e, w.line, w.column, w.path, w.message)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s := generateTest(t, Generator{}, "")
+
+	tests := []struct {
+		name   string
+		config string
+		errors []testValidationError
+	}{
+		{
+			name:   "valid",
+			config: `{"timeout":"1.5s","retries":2,"handle":[{"handler":"static_response","body":"ok","status_code":200}]}`,
+		},
+		{
+			name:   "duration nanoseconds",
+			config: `{"timeout":1000}`,
+		},
+		{
+			name:   "duration string",
+			config: `{"timeout":"5 sec"}`,
+			errors: []testValidationError{{1, 2, "/timeout", "must match one of"}},
+		},
+		{
+			name:   "duration type",
+			config: `{"timeout":true}`,
+			errors: []testValidationError{{1, 2, "/timeout", "must match one of"}},
+		},
+		{
+			name:   "module branch",
+			config: `{"handle":[{"handler":"static_response","close":"yes"}]}`,
+			errors: []testValidationError{{1, 41, "/handle/0/close", "expected boolean, found string"}},
+		},
+		{
+			name:   "unknown module",
+			config: `{"handle":[{"handler":"static"}]}`,
+			errors: []testValidationError{{1, 13, "/handle/0/handler", "must be one of"}},
+		},
+		{
+			name:   "missing inline key",
+			config: `{"handle":[{"body":"ok"}]}`,
+			errors: []testValidationError{{1, 12, "/handle/0", `missing required property "handler"`}},
+		},
+		{
+			name:   "escaped path",
+			config: `{"handle":[{"handler":"static_response","headers":{"X/Y~":"v"}}]}`,
+			errors: []testValidationError{{1, 52, "/handle/0/headers/X~1Y~0", "expected array, found string"}},
+		},
+		{
+			name: "positions",
+			config: `{
+  "retries": "2",
+  "handle": [
+    {"handler": "static_response", "close": 1}
+  ]
+}`,
+			errors: []testValidationError{
+				{2, 3, "/retries", "expected integer, found string"},
+				{4, 36, "/handle/0/close", "expected boolean, found integer"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validateTest(t, s, "test.validate", tt.config, tt.errors)
+		})
+	}
+}
+
+func TestValidatePlaceholders(t *testing.T) {
+	s := generateTest(t, Generator{Placeholders: &Placeholders{}}, "test.placeholders")
+
+	validateTest(t, s, "test.placeholders", `{"port":"{env.PORT}","statuses":[500,"{env.STATUS}"],"weights":{"a":"{env.WEIGHT}"}}`, nil)
+	validateTest(t, s, "test.placeholders", `{"port":"80","statuses":["500"]}`, []testValidationError{
+		{1, 2, "/port", "must match at least one of"},
+		{1, 26, "/statuses/0", "must match at least one of"},
+	})
+}
+
+func TestValidateClosed(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		errors []testValidationError
+	}{
+		{
+			name:   "valid",
+			config: `{"@id":"a","timeout":"1s","handle":[{"@id":2,"handler":"static_response","body":"ok"}]}`,
+		},
+		{
+			name:   "unknown property",
+			config: `{"timeout":"1s","timeuot":"1s"}`,
+			errors: []testValidationError{{1, 17, "/timeuot", `unknown property "timeuot"`}},
+		},
+		{
+			name:   "unknown module property",
+			config: `{"handle":[{"handler":"static_response","bdy":"ok"}]}`,
+			errors: []testValidationError{{1, 41, "/handle/0/bdy", `unknown property "bdy"`}},
+		},
+		{
+			name:   "id type",
+			config: `{"@id":true}`,
+			errors: []testValidationError{{1, 2, "/@id", "must match at least one of"}},
+		},
+	}
+	for _, draft := range []Draft{Draft07, Draft201909, Draft202012} {
+		s := generateTest(t, Generator{Closed: true, Draft: draft}, "")
+		for _, tt := range tests {
+			t.Run(string(draft)+"/"+tt.name, func(t *testing.T) {
+				validateTest(t, s, "test.validate", tt.config, tt.errors)
+			})
+		}
+	}
+}